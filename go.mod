@@ -0,0 +1,3 @@
+module github.com/Paulinhoh/COMP0415
+
+go 1.22
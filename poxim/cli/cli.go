@@ -0,0 +1,221 @@
+// Package cli implementa a linha de comando comum aos binários do poxim.
+// Cada versão chama Main com o seu perfil; as opções ajustam o perfil e os
+// dispositivos da máquina antes da execução.
+package cli
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/Paulinhoh/COMP0415/poxim/core"
+)
+
+// Main executa o simulador com o perfil dado, lendo as opções e os
+// arquivos de entrada e saída de os.Args.
+func Main(perfil core.Config) {
+	if len(os.Args) > 1 && os.Args[1] == "disas" {
+		desmontarPrograma(perfil, os.Args[2:])
+		return
+	}
+
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Uso: %s [opções] <arquivo_entrada> <arquivo_saida>\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "     %s disas <arquivo_entrada> [arquivo_saida]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	razaoMtime := flags.Uint("mtime-ratio", 1, "instruções retiradas por incremento de mtime no CLINT")
+	simbolos := flags.Bool("symbols", false, "anota o pc, os desvios e os traps com <função+deslocamento> (requer ELF com símbolos)")
+	uartSaida := flags.String("uart-out", "-", "destino dos bytes transmitidos pela UART (- = stdout)")
+	uartEntrada := flags.String("uart-in", "", "origem dos bytes recebidos pela UART (- = stdin, vazio = nenhuma)")
+	uartIRQ := flags.Bool("uart-irq", false, "sinaliza as interrupções da UART em MEIP")
+	icache := flags.String("icache", "", "geometria da cache de instruções em tamanho:bloco:vias (vias = full para totalmente associativa)")
+	dcache := flags.String("dcache", "", "geometria da cache de dados em tamanho:bloco:vias")
+	ipolicy := flags.String("ipolicy", "", "política de substituição da cache de instruções: "+strings.Join(core.Policies(), ", "))
+	dpolicy := flags.String("dpolicy", "", "política de substituição da cache de dados: "+strings.Join(core.Policies(), ", "))
+	semente := flags.Int64("cache-seed", 1, "semente da política random")
+	escritaD := flags.String("dcache-write", "wt", "política de escrita da cache de dados: wt (write-through) ou wb (write-back com alocação)")
+	l2 := flags.String("l2", "", "geometria da cache L2 compartilhada em tamanho:bloco:vias (vazio = sem L2)")
+	l2policy := flags.String("l2-policy", "", "política de substituição da L2: "+strings.Join(core.Policies(), ", "))
+	l2inclusiva := flags.Bool("l2-inclusive", false, "mantém a L2 inclusiva, invalidando as cópias nas caches L1 ao substituir um bloco")
+	tempo := flags.Bool("timing", false, "conta ciclos com o modelo de tempo e escreve ciclos, CPI e AMAT no fim do rastro")
+	latencias := flags.String("latency", "", "altera latências do modelo de tempo em classe=ciclos,... (alu, mul, div, fpu, fdiv, branch, l1, l2, mem); implica -timing")
+	preditor := flags.String("bpred", "", "preditor de desvios: "+strings.Join(core.Predictors(), ", ")+" (vazio = sem predição)")
+	bitsPreditor := flags.Int("bpred-bits", core.BitsPreditorPadrao, "bits de índice das tabelas do preditor de desvios")
+	bitsBTB := flags.Int("btb-bits", 6, "bits de índice da BTB usada por jal e jalr (0 = sem BTB)")
+	anotarPredicao := flags.Bool("bpred-trace", false, "anota a predição em cada linha de desvio do rastro")
+	pipeline := flags.Bool("pipeline", false, "conta ciclos e bolhas com o modelo de pipeline de cinco estágios")
+	forwarding := flags.Bool("forwarding", true, "habilita o adiantamento de resultados no modelo de pipeline")
+	diagrama := flags.String("pipeline-diagram", "", "escreve o diagrama do pipeline, um ciclo por linha, no arquivo dado (- = stdout); implica -pipeline")
+	depuracao := flags.Bool("debug", false, "executa no depurador interativo, lendo comandos de stdin")
+	enderecoGDB := flags.String("gdb", "", "atende o gdb remoto em [host]:porta ou unix:/caminho")
+	flags.Parse(os.Args[1:])
+
+	if flags.NArg() < 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	caminhoArquivoEntrada := flags.Arg(0)
+	caminhoArquivoSaida := flags.Arg(1)
+
+	if *razaoMtime == 0 {
+		log.Fatalf("-mtime-ratio deve ser maior que zero")
+	}
+	if *depuracao && *uartEntrada == "-" {
+		log.Fatalf("Opção inválida: -debug lê comandos de stdin e não pode ser usada com -uart-in -")
+	}
+	perfil.RazaoMtime = uint32(*razaoMtime)
+	perfil.AnotarSimbolos = *simbolos
+	if !perfil.Caches {
+		definidas := make(map[string]bool)
+		flags.Visit(func(f *flag.Flag) { definidas[f.Name] = true })
+		for _, nome := range []string{"icache", "dcache", "ipolicy", "dpolicy", "cache-seed", "dcache-write", "l2-policy", "l2-inclusive"} {
+			if definidas[nome] {
+				log.Fatalf("Opção inválida: -%s requer uma versão com caches", nome)
+			}
+		}
+	}
+	for _, c := range []struct {
+		texto, politica string
+		destino         *core.CacheConfig
+	}{{*icache, *ipolicy, &perfil.ICache}, {*dcache, *dpolicy, &perfil.DCache}} {
+		geometria := core.DefaultCacheConfig()
+		if c.texto != "" {
+			var err error
+			if geometria, err = core.ParseCacheConfig(c.texto); err != nil {
+				log.Fatalf("Opção inválida: %v", err)
+			}
+		}
+		geometria.Policy, geometria.Seed = c.politica, *semente
+		if err := geometria.Validate(); err != nil {
+			log.Fatalf("Opção inválida: %v", err)
+		}
+		*c.destino = geometria
+	}
+	switch *escritaD {
+	case "wt":
+	case "wb":
+		perfil.DCache.WriteBack = true
+	default:
+		log.Fatalf("Opção inválida: -dcache-write deve ser wt ou wb")
+	}
+	if *l2 != "" {
+		if !perfil.Caches {
+			log.Fatalf("Opção inválida: -l2 requer uma versão com caches")
+		}
+		geometria, err := core.ParseCacheConfig(*l2)
+		if err == nil {
+			geometria.Policy, geometria.Seed = *l2policy, *semente
+			err = core.ValidateL2(geometria, perfil.ICache, perfil.DCache)
+		}
+		if err != nil {
+			log.Fatalf("Opção inválida: %v", err)
+		}
+		perfil.L2, perfil.L2Inclusiva = geometria, *l2inclusiva
+	}
+
+	if *tempo || *latencias != "" {
+		perfil.ModeloTempo, perfil.Latencias = true, core.DefaultLatencies()
+		if *latencias != "" {
+			var err error
+			if perfil.Latencias, err = core.ParseLatencies(*latencias, perfil.Latencias); err != nil {
+				log.Fatalf("Opção inválida: %v", err)
+			}
+		}
+	}
+
+	if *preditor != "" {
+		if err := core.ValidatePredictor(*preditor, *bitsPreditor, *bitsBTB); err != nil {
+			log.Fatalf("Opção inválida: %v", err)
+		}
+		perfil.Preditor, perfil.BitsPreditor, perfil.BitsBTB = *preditor, *bitsPreditor, *bitsBTB
+		perfil.AnotarPredicao = *anotarPredicao
+	} else if *anotarPredicao {
+		log.Fatalf("Opção inválida: -bpred-trace requer -bpred")
+	}
+
+	perfil.Pipeline = *pipeline || *diagrama != ""
+	perfil.Forwarding = *forwarding
+
+	arquivoSaida, err := os.Create(caminhoArquivoSaida)
+	if err != nil {
+		log.Fatalf("Falha ao criar o arquivo de saída: %v", err)
+	}
+	defer arquivoSaida.Close()
+	writer := bufio.NewWriter(arquivoSaida)
+	defer writer.Flush()
+
+	m := core.New(perfil, writer)
+	if err := conectarUART(m, *uartSaida, *uartEntrada, *uartIRQ); err != nil {
+		log.Fatalf("Falha ao configurar a UART: %v", err)
+	}
+	if *diagrama != "" {
+		saida, err := abrirSaida(*diagrama)
+		if err != nil {
+			log.Fatalf("Falha ao criar o diagrama do pipeline: %v", err)
+		}
+		diagramaBuf := bufio.NewWriter(saida)
+		defer diagramaBuf.Flush()
+		m.SetPipelineDiagram(diagramaBuf)
+	}
+	if err := m.Load(caminhoArquivoEntrada); err != nil {
+		log.Fatalf("Falha ao carregar o programa: %v", err)
+	}
+	switch {
+	case *enderecoGDB != "":
+		err = servirGDB(m, *enderecoGDB)
+	case *depuracao:
+		err = depurar(m, os.Stdin, os.Stdout)
+	default:
+		err = m.Run()
+	}
+	if err != nil {
+		writer.Flush()
+		log.Fatalf("Execução interrompida: %v", err)
+	}
+}
+
+// abrirSaida abre o arquivo de saída dado, ou stdout para "-". O arquivo
+// é fechado na saída do processo.
+func abrirSaida(caminho string) (io.Writer, error) {
+	if caminho == "-" {
+		return os.Stdout, nil
+	}
+	return os.Create(caminho)
+}
+
+// conectarUART mapeia a UART no barramento de m, com a transmissão em
+// saida e a recepção de entrada.
+func conectarUART(m *core.Machine, saida, entrada string, irq bool) error {
+	tx, err := abrirSaida(saida)
+	if err != nil {
+		return err
+	}
+
+	var rx io.Reader
+	switch entrada {
+	case "":
+	case "-":
+		rx = os.Stdin
+	default:
+		arquivo, err := os.Open(entrada)
+		if err != nil {
+			return err
+		}
+		rx = arquivo
+	}
+
+	var linha func(bit uint32, pendente bool)
+	if irq {
+		if !m.Config().Traps {
+			return fmt.Errorf("-uart-irq requer uma versão com interrupções")
+		}
+		linha = m.SetIRQ
+	}
+	return m.Bus.Attach("uart", core.UARTBase, core.UARTSize, core.NewUART(tx, rx, linha))
+}
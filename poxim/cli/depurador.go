@@ -0,0 +1,299 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Paulinhoh/COMP0415/poxim/core"
+)
+
+// depurador é o modo interativo (-debug): a máquina para antes de cada
+// instrução enquanto houver comandos a executar.
+type depurador struct {
+	m           *core.Machine
+	saida       io.Writer
+	breakpoints map[uint32]bool
+}
+
+const ajudaDepurador = `comandos:
+  step [N]             executa N instruções (padrão 1)
+  continue             executa até um breakpoint ou o fim do programa
+  break <end|símbolo>  insere um breakpoint
+  delete [end|símbolo] remove um breakpoint, ou todos
+  info                 lista os breakpoints
+  regs                 mostra pc e os registradores inteiros
+  csr <nome|end>       mostra um CSR
+  mem <end> <tam>      mostra tam bytes a partir de end
+  disas [end] [N]      desmonta N instruções a partir de end (padrão pc)
+  cache                mostra o conteúdo das caches (v3)
+  quit                 encerra
+`
+
+// depurar lê comandos de entrada até o programa terminar ou o usuário
+// sair. As estatísticas são escritas no rastro quando o programa termina.
+func depurar(m *core.Machine, entrada io.Reader, saida io.Writer) error {
+	d := &depurador{m: m, saida: saida, breakpoints: make(map[uint32]bool)}
+	scanner := bufio.NewScanner(entrada)
+	d.mostrarPosicao()
+	for {
+		fmt.Fprint(saida, "(poxim) ")
+		if !scanner.Scan() {
+			fmt.Fprintln(saida)
+			return scanner.Err()
+		}
+		campos := strings.Fields(scanner.Text())
+		if len(campos) == 0 {
+			continue
+		}
+		fim, err := d.executar(campos[0], campos[1:])
+		if err != nil {
+			return err
+		}
+		if fim {
+			return nil
+		}
+	}
+}
+
+// executar trata um comando; fim indica que a sessão terminou.
+func (d *depurador) executar(comando string, args []string) (fim bool, err error) {
+	m := d.m
+	switch comando {
+	case "step", "s":
+		n := uint64(1)
+		if len(args) > 0 {
+			if n, err = strconv.ParseUint(args[0], 0, 64); err != nil {
+				fmt.Fprintf(d.saida, "quantidade inválida: %s\n", args[0])
+				return false, nil
+			}
+		}
+		for i := uint64(0); i < n && !m.Halted; i++ {
+			if err := m.Step(); err != nil {
+				return true, err
+			}
+		}
+		return d.aposExecucao(), nil
+
+	case "continue", "c":
+		for !m.Halted {
+			if err := m.Step(); err != nil {
+				return true, err
+			}
+			if d.breakpoints[m.PC] {
+				fmt.Fprintf(d.saida, "breakpoint em 0x%08x%s\n", m.PC, d.simbolo(m.PC))
+				break
+			}
+		}
+		return d.aposExecucao(), nil
+
+	case "break", "b":
+		if len(args) != 1 {
+			fmt.Fprintln(d.saida, "uso: break <end|símbolo>")
+			return false, nil
+		}
+		if endereco, ok := d.endereco(args[0]); ok {
+			d.breakpoints[endereco] = true
+			fmt.Fprintf(d.saida, "breakpoint em 0x%08x%s\n", endereco, d.simbolo(endereco))
+		}
+
+	case "delete", "d":
+		if len(args) == 0 {
+			d.breakpoints = make(map[uint32]bool)
+			return false, nil
+		}
+		if endereco, ok := d.endereco(args[0]); ok {
+			delete(d.breakpoints, endereco)
+		}
+
+	case "info":
+		d.listarBreakpoints()
+
+	case "regs", "r":
+		fmt.Fprintf(d.saida, "pc   = 0x%08x%s\n", m.PC, d.simbolo(m.PC))
+		for i := 0; i < 32; i++ {
+			fmt.Fprintf(d.saida, "%-4s = 0x%08x", core.XLabel(i), uint32(m.X[i]))
+			if i%4 == 3 {
+				fmt.Fprintln(d.saida)
+			} else {
+				fmt.Fprint(d.saida, "   ")
+			}
+		}
+
+	case "csr":
+		if len(args) != 1 {
+			fmt.Fprintln(d.saida, "uso: csr <nome|end>")
+			return false, nil
+		}
+		endereco, ok := core.CSRAddr(args[0])
+		if !ok {
+			valor, err := strconv.ParseUint(args[0], 0, 12)
+			if err != nil {
+				fmt.Fprintf(d.saida, "CSR desconhecido: %s\n", args[0])
+				return false, nil
+			}
+			endereco = uint32(valor)
+		}
+		fmt.Fprintf(d.saida, "%s (0x%03x) = 0x%08x\n", args[0], endereco, m.ReadCSR(endereco))
+
+	case "mem", "x":
+		if len(args) != 2 {
+			fmt.Fprintln(d.saida, "uso: mem <end> <tam>")
+			return false, nil
+		}
+		endereco, ok := d.endereco(args[0])
+		tam, err := strconv.ParseUint(args[1], 0, 32)
+		if !ok || err != nil {
+			fmt.Fprintln(d.saida, "uso: mem <end> <tam>")
+			return false, nil
+		}
+		d.mostrarMemoria(endereco, uint32(tam))
+
+	case "disas":
+		endereco, n := m.PC, uint64(8)
+		if len(args) > 0 {
+			var ok bool
+			if endereco, ok = d.endereco(args[0]); !ok {
+				return false, nil
+			}
+		}
+		if len(args) > 1 {
+			if n, err = strconv.ParseUint(args[1], 0, 32); err != nil {
+				fmt.Fprintf(d.saida, "quantidade inválida: %s\n", args[1])
+				return false, nil
+			}
+		}
+		for i := uint64(0); i < n; i++ {
+			tamanho, ok := d.desmontar(endereco)
+			if !ok {
+				break
+			}
+			endereco += tamanho
+		}
+
+	case "cache":
+		if m.ICache == nil || m.DCache == nil {
+			fmt.Fprintln(d.saida, "esta versão não tem caches")
+			return false, nil
+		}
+		fmt.Fprintln(d.saida, "icache:")
+		m.ICache.Dump(d.saida)
+		fmt.Fprintln(d.saida, "dcache:")
+		m.DCache.Dump(d.saida)
+		if m.L2 != nil {
+			fmt.Fprintln(d.saida, "l2:")
+			m.L2.Dump(d.saida)
+		}
+
+	case "quit", "q":
+		return true, nil
+
+	case "help", "h", "?":
+		fmt.Fprint(d.saida, ajudaDepurador)
+
+	default:
+		fmt.Fprintf(d.saida, "comando desconhecido: %s (help lista os comandos)\n", comando)
+	}
+	return false, nil
+}
+
+// aposExecucao mostra onde a máquina parou e encerra a sessão se o
+// programa terminou.
+func (d *depurador) aposExecucao() bool {
+	if d.m.Halted {
+		fmt.Fprintln(d.saida, "programa encerrado")
+		d.m.WriteStats()
+		return true
+	}
+	d.mostrarPosicao()
+	return false
+}
+
+// mostrarPosicao desmonta a próxima instrução a executar.
+func (d *depurador) mostrarPosicao() {
+	d.desmontar(d.m.PC)
+}
+
+// desmontar escreve a instrução em endereco e retorna o seu tamanho.
+func (d *depurador) desmontar(endereco uint32) (uint32, bool) {
+	dados := d.m.PeekMemory(endereco, 4)
+	if len(dados) < 2 {
+		fmt.Fprintf(d.saida, "0x%08x: endereço não mapeado\n", endereco)
+		return 0, false
+	}
+	if len(dados) < 4 {
+		dados = dados[:2]
+	}
+	var instrucao uint32
+	for i, b := range dados {
+		instrucao |= uint32(b) << (8 * i)
+	}
+	texto, tamanho, _ := core.Disassemble(instrucao, endereco)
+	marca := " "
+	if d.breakpoints[endereco] {
+		marca = "*"
+	}
+	if simbolo, ok := d.m.Symbol(endereco); ok {
+		fmt.Fprintf(d.saida, "%s0x%08x %s:%s\n", marca, endereco, simbolo, texto)
+	} else {
+		fmt.Fprintf(d.saida, "%s0x%08x:%s\n", marca, endereco, texto)
+	}
+	return tamanho, true
+}
+
+// mostrarMemoria escreve tam bytes a partir de endereco, 16 por linha.
+// Bytes fora da RAM, inclusive os registradores de dispositivos, que
+// teriam efeitos colaterais, aparecem como --.
+func (d *depurador) mostrarMemoria(endereco, tam uint32) {
+	for i := uint32(0); i < tam; i++ {
+		if i%16 == 0 {
+			if i > 0 {
+				fmt.Fprintln(d.saida)
+			}
+			fmt.Fprintf(d.saida, "0x%08x:", endereco+i)
+		}
+		valor := d.m.PeekMemory(endereco+i, 1)
+		if len(valor) == 0 {
+			fmt.Fprint(d.saida, " --")
+			continue
+		}
+		fmt.Fprintf(d.saida, " %02x", valor[0])
+	}
+	fmt.Fprintln(d.saida)
+}
+
+// listarBreakpoints escreve os breakpoints em ordem de endereço.
+func (d *depurador) listarBreakpoints() {
+	enderecos := make([]uint32, 0, len(d.breakpoints))
+	for endereco := range d.breakpoints {
+		enderecos = append(enderecos, endereco)
+	}
+	sort.Slice(enderecos, func(i, j int) bool { return enderecos[i] < enderecos[j] })
+	for _, endereco := range enderecos {
+		fmt.Fprintf(d.saida, "breakpoint em 0x%08x%s\n", endereco, d.simbolo(endereco))
+	}
+}
+
+// endereco interpreta um endereço numérico ou um nome de símbolo.
+func (d *depurador) endereco(arg string) (uint32, bool) {
+	if endereco, ok := d.m.SymbolAddr(arg); ok {
+		return endereco, true
+	}
+	valor, err := strconv.ParseUint(arg, 0, 32)
+	if err != nil {
+		fmt.Fprintf(d.saida, "endereço ou símbolo inválido: %s\n", arg)
+		return 0, false
+	}
+	return uint32(valor), true
+}
+
+// simbolo retorna " <função+deslocamento>" para endereco, se houver.
+func (d *depurador) simbolo(endereco uint32) string {
+	if s, ok := d.m.Symbol(endereco); ok {
+		return " " + s
+	}
+	return ""
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/Paulinhoh/COMP0415/poxim/core"
+)
+
+// desmontarPrograma implementa o comando disas: carrega o programa como
+// na execução, sem executá-lo, e desmonta os seus segmentos de código.
+func desmontarPrograma(perfil core.Config, args []string) {
+	flags := flag.NewFlagSet(os.Args[0]+" disas", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Uso: %s disas <arquivo_entrada> [arquivo_saida]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	m := core.New(perfil, nil)
+	if err := m.Load(flags.Arg(0)); err != nil {
+		log.Fatalf("Falha ao carregar o programa: %v", err)
+	}
+	var saida io.Writer = os.Stdout
+	if flags.NArg() == 2 {
+		arquivo, err := os.Create(flags.Arg(1))
+		if err != nil {
+			log.Fatalf("Falha ao criar o arquivo de saída: %v", err)
+		}
+		defer arquivo.Close()
+		saida = arquivo
+	}
+	writer := bufio.NewWriter(saida)
+	defer writer.Flush()
+
+	if ilegais := desmontarSegmentos(m, writer); ilegais > 0 {
+		fmt.Fprintf(writer, "# %d codificações ilegais\n", ilegais)
+	}
+}
+
+// desmontarSegmentos escreve cada instrução dos segmentos de código de m
+// com endereço, palavra e mnemônico, e retorna quantas são ilegais. Se o
+// programa não marca nenhum segmento como código, todos são desmontados.
+func desmontarSegmentos(m *core.Machine, w io.Writer) int {
+	segmentos := m.Segments()
+	codigo := segmentos[:0:0]
+	for _, s := range segmentos {
+		if s.Exec {
+			codigo = append(codigo, s)
+		}
+	}
+	if len(codigo) == 0 {
+		codigo = segmentos
+	}
+
+	ilegais := 0
+	for i, s := range codigo {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fim := s.Addr + s.Size
+		for pc := s.Addr; pc < fim; {
+			if simbolo, ok := m.Symbol(pc); ok && !strings.Contains(simbolo, "+") {
+				fmt.Fprintf(w, "%s:\n", simbolo)
+			}
+
+			tam := uint32(4)
+			if fim-pc < 4 {
+				tam = 2
+			}
+			instrucao, _ := m.Bus.Read(pc, tam)
+			if fim-pc < 2 {
+				fmt.Fprintf(w, "0x%08x: %02x        (byte final)\n", pc, instrucao&0xFF)
+				break
+			}
+			texto, tamanho, valido := core.Disassemble(instrucao, pc)
+			if tamanho > fim-pc {
+				fmt.Fprintf(w, "0x%08x: %04x      (instrução truncada)\n", pc, instrucao&0xFFFF)
+				ilegais++
+				break
+			}
+			if !valido {
+				ilegais++
+			}
+			if tamanho == 2 {
+				fmt.Fprintf(w, "0x%08x: %04x      %s\n", pc, instrucao&0xFFFF, texto)
+			} else {
+				fmt.Fprintf(w, "0x%08x: %08x  %s\n", pc, instrucao, texto)
+			}
+			pc += tamanho
+		}
+	}
+	return ilegais
+}
@@ -0,0 +1,452 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/Paulinhoh/COMP0415/poxim/core"
+)
+
+// Sinais enviados nas respostas de parada
+const (
+	sinalTrap = 5 // SIGTRAP: passo, breakpoint
+	sinalInt  = 2 // SIGINT: interrupção (Ctrl-C) pedida pelo gdb
+)
+
+// tamanhoPacote é o PacketSize anunciado em qSupported. Uma leitura de
+// memória responde com dois dígitos por byte, então m aceita no máximo
+// tamanhoPacote/2 bytes.
+const tamanhoPacote = 0x4000
+
+// stubGDB implementa o lado alvo do GDB Remote Serial Protocol sobre uma
+// conexão: registradores x0..x31 e pc, memória pelo barramento,
+// breakpoints de software (Z0), passo e continuação.
+type stubGDB struct {
+	m           *core.Machine
+	conexao     io.ReadWriter
+	entrada     chan byte
+	pendentes   []byte // Bytes recebidos durante a execução, antes de um pacote
+	semAck      bool
+	encerrado   bool // Sessão terminada pelo gdb com k
+	breakpoints map[uint32]bool
+}
+
+// servirGDB espera uma conexão do gdb em endereco ("unix:/caminho" ou
+// "[host]:porta") e atende a sessão até o programa terminar ou o gdb se
+// desconectar.
+func servirGDB(m *core.Machine, endereco string) error {
+	rede := "tcp"
+	if caminho, ok := strings.CutPrefix(endereco, "unix:"); ok {
+		rede, endereco = "unix", caminho
+	}
+	ouvinte, err := net.Listen(rede, endereco)
+	if err != nil {
+		return err
+	}
+	defer ouvinte.Close()
+	log.Printf("Aguardando o gdb em %s:%s", rede, endereco)
+
+	conexao, err := ouvinte.Accept()
+	if err != nil {
+		return err
+	}
+	defer conexao.Close()
+
+	s := &stubGDB{m: m, conexao: conexao, entrada: make(chan byte, 4096), breakpoints: make(map[uint32]bool)}
+	go s.ler()
+	if err := s.atender(); err != nil {
+		return err
+	}
+	// Sem o gdb (detach ou conexão fechada), o programa executa até o fim
+	if !s.encerrado && !m.Halted {
+		return m.Run()
+	}
+	return nil
+}
+
+// ler repassa os bytes da conexão ao canal de entrada, permitindo checar
+// um Ctrl-C (0x03) sem bloquear enquanto a máquina executa.
+func (s *stubGDB) ler() {
+	leitor := bufio.NewReader(s.conexao)
+	for {
+		c, err := leitor.ReadByte()
+		if err != nil {
+			close(s.entrada)
+			return
+		}
+		s.entrada <- c
+	}
+}
+
+// proximoByte retorna o próximo byte da conexão, começando pelos que
+// continuar guardou em pendentes.
+func (s *stubGDB) proximoByte() (byte, bool) {
+	if len(s.pendentes) > 0 {
+		c := s.pendentes[0]
+		s.pendentes = s.pendentes[1:]
+		return c, true
+	}
+	c, ok := <-s.entrada
+	return c, ok
+}
+
+// receberPacote retorna o conteúdo do próximo pacote $...#cs, confirmando
+// o recebimento se o modo sem ack não estiver ativo.
+func (s *stubGDB) receberPacote() (string, error) {
+	for {
+		c, ok := s.proximoByte()
+		if !ok {
+			return "", io.EOF
+		}
+		if c != '$' {
+			continue // Acks e interrupções fora de execução são ignorados
+		}
+		var pacote []byte
+		for {
+			if c, ok = s.proximoByte(); !ok {
+				return "", io.EOF
+			}
+			if c == '#' {
+				break
+			}
+			pacote = append(pacote, c)
+		}
+		var cs [2]byte
+		for i := range cs {
+			if cs[i], ok = s.proximoByte(); !ok {
+				return "", io.EOF
+			}
+		}
+		soma, err := strconv.ParseUint(string(cs[:]), 16, 8)
+		if !s.semAck {
+			if err != nil || uint8(soma) != checksum(pacote) {
+				io.WriteString(s.conexao, "-")
+				continue
+			}
+			io.WriteString(s.conexao, "+")
+		}
+		return string(pacote), nil
+	}
+}
+
+// enviar escreve um pacote com o checksum.
+func (s *stubGDB) enviar(dados string) error {
+	_, err := fmt.Fprintf(s.conexao, "$%s#%02x", dados, checksum([]byte(dados)))
+	return err
+}
+
+func checksum(dados []byte) uint8 {
+	var soma uint8
+	for _, c := range dados {
+		soma += c
+	}
+	return soma
+}
+
+// atender processa os pacotes até o fim da sessão.
+func (s *stubGDB) atender() error {
+	for {
+		pacote, err := s.receberPacote()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		resposta, fim, err := s.tratar(pacote)
+		if err != nil {
+			return err
+		}
+		if s.encerrado {
+			return nil // k não tem resposta
+		}
+		if err := s.enviar(resposta); err != nil {
+			return err
+		}
+		if fim {
+			return nil
+		}
+	}
+}
+
+// tratar executa um comando e retorna a resposta; fim encerra a sessão.
+func (s *stubGDB) tratar(pacote string) (resposta string, fim bool, err error) {
+	m := s.m
+	if pacote == "" {
+		return "", false, nil
+	}
+	switch comando, args := pacote[0], pacote[1:]; comando {
+	case '?':
+		return fmt.Sprintf("S%02x", sinalTrap), false, nil
+
+	case 'q':
+		return s.consulta(args), false, nil
+
+	case 'Q':
+		if args == "StartNoAckMode" {
+			s.semAck = true
+			return "OK", false, nil
+		}
+		return "", false, nil
+
+	case 'H':
+		return "OK", false, nil // Só há um hart
+
+	case 'g':
+		var b strings.Builder
+		for i := 0; i < 32; i++ {
+			b.WriteString(hexLE(uint32(m.X[i])))
+		}
+		b.WriteString(hexLE(m.PC))
+		return b.String(), false, nil
+
+	case 'G':
+		if len(args) < 33*8 {
+			return "E01", false, nil
+		}
+		for i := 0; i < 33; i++ {
+			valor, ok := lerHexLE(args[i*8 : i*8+8])
+			if !ok {
+				return "E01", false, nil
+			}
+			s.escreverRegistrador(i, valor)
+		}
+		return "OK", false, nil
+
+	case 'p':
+		n, err := strconv.ParseUint(args, 16, 32)
+		if err != nil || n > 32 {
+			return "E01", false, nil
+		}
+		if n == 32 {
+			return hexLE(m.PC), false, nil
+		}
+		return hexLE(uint32(m.X[n])), false, nil
+
+	case 'P':
+		numero, valorHex, ok := strings.Cut(args, "=")
+		n, err := strconv.ParseUint(numero, 16, 32)
+		valor, valido := lerHexLE(valorHex)
+		if !ok || err != nil || n > 32 || !valido {
+			return "E01", false, nil
+		}
+		s.escreverRegistrador(int(n), valor)
+		return "OK", false, nil
+
+	case 'm':
+		endereco, tam, ok := enderecoTamanho(args)
+		if !ok || tam > tamanhoPacote/2 {
+			return "E01", false, nil
+		}
+		// Só a RAM é lida, com os dados sujos das caches; registradores de
+		// dispositivos não são lidos para não consumir seus dados
+		dados := m.PeekMemory(endereco, tam)
+		if len(dados) == 0 && tam > 0 {
+			return "E14", false, nil // EFAULT
+		}
+		return hex.EncodeToString(dados), false, nil
+
+	case 'M':
+		cabecalho, dadosHex, ok := strings.Cut(args, ":")
+		endereco, tam, valido := enderecoTamanho(cabecalho)
+		dados, err := hex.DecodeString(dadosHex)
+		if !ok || !valido || err != nil || uint32(len(dados)) != tam {
+			return "E01", false, nil
+		}
+		if m.PokeMemory(endereco, dados) != len(dados) {
+			return "E14", false, nil
+		}
+		return "OK", false, nil
+
+	case 'Z', 'z':
+		tipo, resto, _ := strings.Cut(args, ",")
+		if tipo != "0" {
+			return "", false, nil // Apenas breakpoints de software
+		}
+		endereco, _, ok := enderecoTamanho(resto)
+		if !ok {
+			return "E01", false, nil
+		}
+		if comando == 'Z' {
+			s.breakpoints[endereco] = true
+		} else {
+			delete(s.breakpoints, endereco)
+		}
+		return "OK", false, nil
+
+	case 's':
+		if err := s.retomar(args); err != nil {
+			return "", true, err
+		}
+		if err := m.Step(); err != nil {
+			return "", true, err
+		}
+		return s.parada(sinalTrap), m.Halted, nil
+
+	case 'c':
+		if err := s.retomar(args); err != nil {
+			return "", true, err
+		}
+		return s.continuar()
+
+	case 'D':
+		return "OK", true, nil
+
+	case 'k':
+		s.encerrado = true
+		return "", true, nil
+	}
+	return "", false, nil // Comando não suportado
+}
+
+// consulta responde aos pacotes q*.
+func (s *stubGDB) consulta(args string) string {
+	switch {
+	case strings.HasPrefix(args, "Supported"):
+		return fmt.Sprintf("PacketSize=%x;qXfer:features:read+;swbreak+;QStartNoAckMode+", tamanhoPacote)
+	case args == "Attached":
+		return "1"
+	case args == "C":
+		return "QC1"
+	case args == "fThreadInfo":
+		return "m1"
+	case args == "sThreadInfo":
+		return "l"
+	case strings.HasPrefix(args, "Xfer:features:read:target.xml:"):
+		deslocamento, tam, ok := enderecoTamanho(strings.TrimPrefix(args, "Xfer:features:read:target.xml:"))
+		if !ok {
+			return "E01"
+		}
+		xml := descricaoAlvo()
+		if deslocamento >= uint32(len(xml)) {
+			return "l"
+		}
+		fim := min(deslocamento+tam, uint32(len(xml)))
+		prefixo := "m"
+		if fim == uint32(len(xml)) {
+			prefixo = "l"
+		}
+		return prefixo + xml[deslocamento:fim]
+	}
+	return ""
+}
+
+// retomar aplica o endereço opcional de "s addr" e "c addr".
+func (s *stubGDB) retomar(args string) error {
+	if args == "" {
+		return nil
+	}
+	endereco, err := strconv.ParseUint(args, 16, 32)
+	if err != nil {
+		return fmt.Errorf("endereço de retomada inválido: %s", args)
+	}
+	s.m.PC = uint32(endereco)
+	return nil
+}
+
+// continuar executa até um breakpoint, o fim do programa ou um Ctrl-C.
+func (s *stubGDB) continuar() (resposta string, fim bool, err error) {
+	m := s.m
+	for passos := 0; !m.Halted; passos++ {
+		if err := m.Step(); err != nil {
+			return "", true, err
+		}
+		if s.breakpoints[m.PC] {
+			return s.parada(sinalTrap) + "swbreak:;", false, nil
+		}
+		// O Ctrl-C chega como o byte 0x03 fora de pacote; os demais bytes
+		// ficam guardados para receberPacote
+		if passos%1024 == 0 {
+			select {
+			case c, ok := <-s.entrada:
+				if !ok {
+					return "", true, nil
+				}
+				if c == 0x03 {
+					return s.parada(sinalInt), false, nil
+				}
+				s.pendentes = append(s.pendentes, c)
+			default:
+			}
+		}
+	}
+	return s.parada(sinalTrap), true, nil
+}
+
+// parada monta a resposta de parada; se o programa terminou, informa a
+// saída (W00) e escreve as estatísticas no rastro.
+func (s *stubGDB) parada(sinal int) string {
+	if s.m.Halted {
+		s.m.WriteStats()
+		return "W00"
+	}
+	return fmt.Sprintf("T%02x20:%s;", sinal, hexLE(s.m.PC))
+}
+
+// escreverRegistrador atribui valor a x[n] (n < 32) ou ao pc (n == 32).
+func (s *stubGDB) escreverRegistrador(n int, valor uint32) {
+	switch {
+	case n == 32:
+		s.m.PC = valor
+	case n > 0:
+		s.m.X[n] = int32(valor)
+	}
+}
+
+// hexLE codifica valor em hexadecimal na ordem de bytes do alvo.
+func hexLE(valor uint32) string {
+	return fmt.Sprintf("%02x%02x%02x%02x", byte(valor), byte(valor>>8), byte(valor>>16), byte(valor>>24))
+}
+
+// lerHexLE decodifica uma palavra em hexadecimal na ordem do alvo.
+func lerHexLE(texto string) (uint32, bool) {
+	dados, err := hex.DecodeString(texto)
+	if err != nil || len(dados) != 4 {
+		return 0, false
+	}
+	return uint32(dados[0]) | uint32(dados[1])<<8 | uint32(dados[2])<<16 | uint32(dados[3])<<24, true
+}
+
+// enderecoTamanho decodifica "endereço,tamanho" em hexadecimal.
+func enderecoTamanho(args string) (endereco, tam uint32, ok bool) {
+	a, t, ok := strings.Cut(args, ",")
+	if !ok {
+		return 0, 0, false
+	}
+	e, err1 := strconv.ParseUint(a, 16, 32)
+	n, err2 := strconv.ParseUint(t, 16, 32)
+	return uint32(e), uint32(n), err1 == nil && err2 == nil
+}
+
+// descricaoAlvo gera o target.xml do RV32 com os registradores x e pc.
+func descricaoAlvo() string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0"?>
+<!DOCTYPE target SYSTEM "gdb-target.dtd">
+<target version="1.0">
+<architecture>riscv:rv32</architecture>
+<feature name="org.gnu.gdb.riscv.cpu">
+`)
+	for i := 0; i < 32; i++ {
+		tipo := "int"
+		switch i {
+		case 1:
+			tipo = "code_ptr"
+		case 2, 8:
+			tipo = "data_ptr"
+		}
+		fmt.Fprintf(&b, "<reg name=\"%s\" bitsize=\"32\" type=\"%s\" regnum=\"%d\"/>\n", core.XLabel(i), tipo, i)
+	}
+	b.WriteString(`<reg name="pc" bitsize="32" type="code_ptr" regnum="32"/>
+</feature>
+</target>
+`)
+	return b.String()
+}
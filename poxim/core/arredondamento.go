@@ -0,0 +1,408 @@
+package core
+
+import (
+	"math"
+	"math/big"
+)
+
+// Aritmética de precisão simples (RV32F) com os cinco modos de
+// arredondamento do RISC-V. Os resultados são calculados de forma exata (ou
+// com precisão suficiente para não haver arredondamento duplo) em big.Float
+// e só então arredondados para float32.
+
+// Modos de arredondamento (campo rm e CSR frm)
+const (
+	RM_RNE = 0b000 // Mais próximo, empate para par
+	RM_RTZ = 0b001 // Em direção a zero
+	RM_RDN = 0b010 // Para baixo (-inf)
+	RM_RUP = 0b011 // Para cima (+inf)
+	RM_RMM = 0b100 // Mais próximo, empate para longe do zero
+	RM_DYN = 0b111 // Usa o modo em frm
+)
+
+// Bits de fflags
+const (
+	FFLAG_NX = 1 << 0 // Inexato
+	FFLAG_UF = 1 << 1 // Underflow
+	FFLAG_OF = 1 << 2 // Overflow
+	FFLAG_DZ = 1 << 3 // Divisão por zero
+	FFLAG_NV = 1 << 4 // Operação inválida
+)
+
+const (
+	nanCanonico  uint32 = 0x7fc00000
+	maxFinitoF32 uint32 = 0x7f7fffff
+	infF32       uint32 = 0x7f800000
+	sinalF32     uint32 = 0x80000000
+)
+
+// Precisão usada nos cálculos intermediários; suficiente para representar
+// exatamente somas e produtos de operandos de precisão simples.
+const precExata = 1024
+
+func ehNaN(b uint32) bool  { return b&0x7fffffff > infF32 }
+func ehSNaN(b uint32) bool { return ehNaN(b) && b&0x00400000 == 0 }
+func ehInf(b uint32) bool  { return b&0x7fffffff == infF32 }
+func ehZero(b uint32) bool { return b&0x7fffffff == 0 }
+
+// paraBig converte um float32 finito em big.Float exato.
+func paraBig(b uint32) *big.Float {
+	return new(big.Float).SetPrec(precExata).SetFloat64(float64(math.Float32frombits(b)))
+}
+
+// arredondarInteiro arredonda o valor não negativo x para um inteiro
+// segundo rm. negativo indica o sinal do número original, necessário para
+// os modos direcionados.
+func arredondarInteiro(x *big.Float, rm uint32, negativo bool) (*big.Int, bool) {
+	n, _ := x.Int(nil)
+	frac := new(big.Float).SetPrec(precExata).Sub(x, new(big.Float).SetInt(n))
+	if frac.Sign() == 0 {
+		return n, false
+	}
+
+	meio := frac.Cmp(big.NewFloat(0.5))
+	subir := false
+	switch rm {
+	case RM_RNE:
+		subir = meio > 0 || (meio == 0 && n.Bit(0) == 1)
+	case RM_RTZ:
+		subir = false
+	case RM_RDN:
+		subir = negativo
+	case RM_RUP:
+		subir = !negativo
+	case RM_RMM:
+		subir = meio >= 0
+	}
+	if subir {
+		n.Add(n, big.NewInt(1))
+	}
+	return n, true
+}
+
+// arredondarF32 arredonda o valor finito e não nulo v para precisão simples
+// segundo rm, retornando os bits do resultado e as flags geradas.
+func arredondarF32(v *big.Float, rm uint32) (uint32, uint32) {
+	negativo := v.Signbit()
+	var sinal uint32
+	if negativo {
+		sinal = sinalF32
+	}
+	a := new(big.Float).SetPrec(precExata).Abs(v)
+	exp := a.MantExp(nil) // a em [2^(exp-1), 2^exp)
+
+	var flags uint32
+	var bits uint32
+	if exp >= -125 {
+		// Faixa normal: 24 bits significativos
+		x := new(big.Float).SetPrec(precExata).SetMantExp(a, 24-exp)
+		n, inexato := arredondarInteiro(x, rm, negativo)
+		if n.BitLen() > 24 {
+			n.Rsh(n, 1)
+			exp++
+		}
+		if exp+126 >= 255 {
+			return overflowF32(sinal, rm), FFLAG_OF | FFLAG_NX
+		}
+		bits = uint32(exp+126)<<23 | (uint32(n.Uint64()) &^ (1 << 23))
+		if inexato {
+			flags |= FFLAG_NX
+		}
+	} else {
+		// Faixa subnormal: unidades de 2^-149
+		x := new(big.Float).SetPrec(precExata).SetMantExp(a, 149)
+		n, inexato := arredondarInteiro(x, rm, negativo)
+		bits = uint32(n.Uint64())
+		if inexato {
+			flags |= FFLAG_NX
+
+			// Detecção de tininess após o arredondamento: o valor só não é
+			// pequeno se, arredondado com expoente ilimitado, atingir 2^-126.
+			y := new(big.Float).SetPrec(precExata).SetMantExp(a, 24-exp)
+			n2, _ := arredondarInteiro(y, rm, negativo)
+			if !(exp == -126 && n2.BitLen() > 24) {
+				flags |= FFLAG_UF
+			}
+		}
+	}
+	return sinal | bits, flags
+}
+
+// overflowF32 retorna o resultado de um overflow segundo o modo rm.
+func overflowF32(sinal uint32, rm uint32) uint32 {
+	switch rm {
+	case RM_RTZ:
+		return sinal | maxFinitoF32
+	case RM_RDN:
+		if sinal != 0 {
+			return sinal | infF32
+		}
+		return maxFinitoF32
+	case RM_RUP:
+		if sinal != 0 {
+			return sinal | maxFinitoF32
+		}
+		return infF32
+	}
+	return sinal | infF32
+}
+
+// zeroExato retorna o zero resultante de uma soma exata nula: o sinal dos
+// operandos quando iguais, senão +0 (ou -0 em RDN).
+func zeroExato(sinalA, sinalB uint32, rm uint32) uint32 {
+	if sinalA == sinalB {
+		return sinalA
+	}
+	if rm == RM_RDN {
+		return sinalF32
+	}
+	return 0
+}
+
+// nanEntrada trata operandos NaN: devolve o NaN canônico e sinaliza NV se
+// algum for sinalizador.
+func nanEntrada(operandos ...uint32) (uint32, uint32, bool) {
+	algumNaN := false
+	var flags uint32
+	for _, b := range operandos {
+		if ehNaN(b) {
+			algumNaN = true
+		}
+		if ehSNaN(b) {
+			flags |= FFLAG_NV
+		}
+	}
+	return nanCanonico, flags, algumNaN
+}
+
+// finalizar arredonda o resultado exato, tratando o zero exato à parte.
+func finalizar(v *big.Float, zero uint32, rm uint32) (uint32, uint32) {
+	if v.Sign() == 0 {
+		return zero, 0
+	}
+	return arredondarF32(v, rm)
+}
+
+func fAdd(a, b uint32, rm uint32) (uint32, uint32) {
+	if r, f, ok := nanEntrada(a, b); ok {
+		return r, f
+	}
+	if ehInf(a) || ehInf(b) {
+		if ehInf(a) && ehInf(b) && (a^b)&sinalF32 != 0 {
+			return nanCanonico, FFLAG_NV // inf - inf
+		}
+		if ehInf(a) {
+			return a, 0
+		}
+		return b, 0
+	}
+	soma := new(big.Float).SetPrec(precExata).Add(paraBig(a), paraBig(b))
+	return finalizar(soma, zeroExato(a&sinalF32, b&sinalF32, rm), rm)
+}
+
+func fMul(a, b uint32, rm uint32) (uint32, uint32) {
+	if r, f, ok := nanEntrada(a, b); ok {
+		return r, f
+	}
+	sinal := (a ^ b) & sinalF32
+	if (ehInf(a) && ehZero(b)) || (ehZero(a) && ehInf(b)) {
+		return nanCanonico, FFLAG_NV
+	}
+	if ehInf(a) || ehInf(b) {
+		return sinal | infF32, 0
+	}
+	produto := new(big.Float).SetPrec(precExata).Mul(paraBig(a), paraBig(b))
+	return finalizar(produto, sinal, rm)
+}
+
+func fDiv(a, b uint32, rm uint32) (uint32, uint32) {
+	if r, f, ok := nanEntrada(a, b); ok {
+		return r, f
+	}
+	sinal := (a ^ b) & sinalF32
+	switch {
+	case (ehInf(a) && ehInf(b)) || (ehZero(a) && ehZero(b)):
+		return nanCanonico, FFLAG_NV
+	case ehInf(a):
+		return sinal | infF32, 0
+	case ehInf(b) || ehZero(a):
+		return sinal, 0
+	case ehZero(b):
+		return sinal | infF32, FFLAG_DZ
+	}
+	// 64 bits bastam: um quociente inexato nunca cai exatamente sobre um
+	// ponto de arredondamento de 25 bits.
+	quociente := new(big.Float).SetPrec(64).Quo(paraBig(a), paraBig(b))
+	return arredondarF32(quociente, rm)
+}
+
+func fSqrt(a uint32, rm uint32) (uint32, uint32) {
+	if r, f, ok := nanEntrada(a); ok {
+		return r, f
+	}
+	switch {
+	case ehZero(a):
+		return a, 0
+	case a&sinalF32 != 0:
+		return nanCanonico, FFLAG_NV
+	case ehInf(a):
+		return a, 0
+	}
+	raiz := new(big.Float).SetPrec(64).Sqrt(paraBig(a))
+	return arredondarF32(raiz, rm)
+}
+
+// fMulAdd calcula (a*b)+c com um único arredondamento. negProduto e negC
+// invertem os sinais do produto e da parcela para fmsub/fnmsub/fnmadd.
+func fMulAdd(a, b, c uint32, negProduto, negC bool, rm uint32) (uint32, uint32) {
+	// inf*0 é inválido mesmo que c seja um NaN silencioso
+	if (ehInf(a) && ehZero(b)) || (ehZero(a) && ehInf(b)) {
+		return nanCanonico, FFLAG_NV
+	}
+	if r, f, ok := nanEntrada(a, b, c); ok {
+		return r, f
+	}
+
+	sinalP := (a ^ b) & sinalF32
+	if negProduto {
+		sinalP ^= sinalF32
+	}
+	if negC {
+		c ^= sinalF32
+	}
+
+	if ehInf(a) || ehInf(b) {
+		if ehInf(c) && c&sinalF32 != sinalP {
+			return nanCanonico, FFLAG_NV
+		}
+		return sinalP | infF32, 0
+	}
+	if ehInf(c) {
+		return c, 0
+	}
+
+	produto := new(big.Float).SetPrec(precExata).Mul(paraBig(a), paraBig(b))
+	if negProduto {
+		produto.Neg(produto)
+	}
+	soma := new(big.Float).SetPrec(precExata).Add(produto, paraBig(c))
+	return finalizar(soma, zeroExato(sinalP, c&sinalF32, rm), rm)
+}
+
+// fMinMax implementa fmin.s/fmax.s, com -0 < +0 e NaN tratado como ausente.
+func fMinMax(a, b uint32, maximo bool) (uint32, uint32) {
+	var flags uint32
+	if ehSNaN(a) || ehSNaN(b) {
+		flags = FFLAG_NV
+	}
+	switch {
+	case ehNaN(a) && ehNaN(b):
+		return nanCanonico, flags
+	case ehNaN(a):
+		return b, flags
+	case ehNaN(b):
+		return a, flags
+	}
+	menor := fMenor(a, b) || (ehZero(a) && ehZero(b) && a&sinalF32 != 0)
+	if menor != maximo {
+		return a, flags
+	}
+	return b, flags
+}
+
+// fMenor compara dois valores que não são NaN.
+func fMenor(a, b uint32) bool {
+	return math.Float32frombits(a) < math.Float32frombits(b)
+}
+
+// fComparar implementa feq.s, flt.s e fle.s. feq é silenciosa: só sinaliza
+// NV para sNaN; flt e fle sinalizam para qualquer NaN.
+func fComparar(a, b uint32, funct3 uint32) (bool, uint32) {
+	if ehNaN(a) || ehNaN(b) {
+		if funct3 != 0b010 || ehSNaN(a) || ehSNaN(b) {
+			return false, FFLAG_NV
+		}
+		return false, 0
+	}
+	fa, fb := math.Float32frombits(a), math.Float32frombits(b)
+	switch funct3 {
+	case 0b010: // feq
+		return fa == fb, 0
+	case 0b001: // flt
+		return fa < fb, 0
+	}
+	return fa <= fb, 0 // fle
+}
+
+// fClass implementa fclass.s.
+func fClass(a uint32) uint32 {
+	negativo := a&sinalF32 != 0
+	expoente := (a >> 23) & 0xFF
+	switch {
+	case ehSNaN(a):
+		return 1 << 8
+	case ehNaN(a):
+		return 1 << 9
+	case ehInf(a) && negativo:
+		return 1 << 0
+	case ehInf(a):
+		return 1 << 7
+	case ehZero(a) && negativo:
+		return 1 << 3
+	case ehZero(a):
+		return 1 << 4
+	case expoente == 0 && negativo:
+		return 1 << 2
+	case expoente == 0:
+		return 1 << 5
+	case negativo:
+		return 1 << 1
+	}
+	return 1 << 6
+}
+
+// fParaInteiro implementa fcvt.w.s e fcvt.wu.s, saturando valores fora da
+// faixa com a flag NV.
+func fParaInteiro(a uint32, semSinal bool, rm uint32) (uint32, uint32) {
+	minimo, maximo := big.NewInt(math.MinInt32), big.NewInt(math.MaxInt32)
+	if semSinal {
+		minimo, maximo = big.NewInt(0), big.NewInt(math.MaxUint32)
+	}
+
+	negativo := a&sinalF32 != 0
+	if ehNaN(a) || (ehInf(a) && !negativo) {
+		return uint32(maximo.Int64()), FFLAG_NV
+	}
+	if ehInf(a) {
+		return uint32(minimo.Int64()), FFLAG_NV
+	}
+
+	magnitude := paraBig(a &^ sinalF32)
+	n, inexato := arredondarInteiro(magnitude, rm, negativo)
+	if negativo {
+		n.Neg(n)
+	}
+	if n.Cmp(minimo) < 0 {
+		return uint32(minimo.Int64()), FFLAG_NV
+	}
+	if n.Cmp(maximo) > 0 {
+		return uint32(maximo.Int64()), FFLAG_NV
+	}
+
+	var flags uint32
+	if inexato {
+		flags = FFLAG_NX
+	}
+	return uint32(n.Int64()), flags
+}
+
+// fDeInteiro implementa fcvt.s.w e fcvt.s.wu.
+func fDeInteiro(v uint32, semSinal bool, rm uint32) (uint32, uint32) {
+	x := new(big.Float).SetPrec(64)
+	if semSinal {
+		x.SetUint64(uint64(v))
+	} else {
+		x.SetInt64(int64(int32(v)))
+	}
+	return finalizar(x, 0, rm)
+}
@@ -0,0 +1,145 @@
+package core
+
+import (
+	"fmt"
+)
+
+// Nomes das operações atômicas indexados por funct5
+var amoInst = map[uint32]string{
+	0b00010: "lr.w",
+	0b00011: "sc.w",
+	0b00001: "amoswap.w",
+	0b00000: "amoadd.w",
+	0b00100: "amoxor.w",
+	0b01100: "amoand.w",
+	0b01000: "amoor.w",
+	0b10000: "amomin.w",
+	0b10100: "amomax.w",
+	0b11000: "amominu.w",
+	0b11100: "amomaxu.w",
+}
+
+// invalidarReserva desfaz a reserva de lr.w se algum dos tam bytes escritos
+// a partir de endereco cair na palavra reservada.
+func (m *Machine) invalidarReserva(endereco, tam uint32) {
+	inicio, fim := uint64(endereco), uint64(endereco)+uint64(tam)
+	if m.reservaValida && inicio < uint64(m.reserva)+4 && uint64(m.reserva) < fim {
+		m.reservaValida = false
+	}
+}
+
+// executarAtomica executa lr.w, sc.w e as instruções AMO*.W.
+func (m *Machine) executarAtomica(instrucao uint32) {
+	x := m.X
+	pc := m.PC
+	writer := m.trace
+
+	_, rd, rs1, rs2, funct3, _ := campos(instrucao)
+	funct5 := campoRs3(instrucao)
+
+	inst, ok := amoInst[funct5]
+	if !ok || funct3 != 0b010 || (funct5 == 0b00010 && rs2 != 0) {
+		m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+		return
+	}
+
+	// Bits de ordenação aq/rl apenas acompanham o mnemônico
+	switch (instrucao >> 25) & 0x3 {
+	case 0b10:
+		inst += ".aq"
+	case 0b01:
+		inst += ".rl"
+	case 0b11:
+		inst += ".aqrl"
+	}
+
+	enderecoMem := uint32(x[rs1])
+
+	// lr.w falha como leitura; sc.w e AMOs, como escrita
+	codigoDesalinhado, codigoAcesso := uint32(EXC_STORE_ADDRESS_MISALIGNED), uint32(EXC_STORE_ACCESS_FAULT)
+	if funct5 == 0b00010 {
+		codigoDesalinhado, codigoAcesso = EXC_LOAD_ADDRESS_MISALIGNED, EXC_LOAD_ACCESS_FAULT
+	}
+	if enderecoMem&0x3 != 0 {
+		m.excecao(codigoDesalinhado, enderecoMem)
+		return
+	}
+
+	switch funct5 {
+	case 0b00010: // lr.w
+		data, ok := m.lerDados(enderecoMem, 4)
+		if !ok {
+			m.excecao(codigoAcesso, enderecoMem)
+			return
+		}
+		m.reserva, m.reservaValida = enderecoMem, true
+		fmt.Fprintf(writer, "%s:%-7s%s,(%s)   %s=mem[0x%08x]=0x%08x\n", m.rotuloPC(pc), m.nomeInst(inst), xLabel[rd], xLabel[rs1], xLabel[rd], enderecoMem, data)
+		if rd != 0 {
+			x[rd] = int32(data)
+		}
+
+	case 0b00011: // sc.w
+		var resultado int32 = 1 // Falha: reserva ausente ou em outro endereço
+		if m.reservaValida && m.reserva == enderecoMem {
+			if !m.escreverDados(enderecoMem, 4, uint32(x[rs2])) {
+				m.excecao(codigoAcesso, enderecoMem)
+				return
+			}
+			resultado = 0
+			fmt.Fprintf(writer, "%s:%-7s%s,%s,(%s)   mem[0x%08x]=0x%08x,%s=0x%08x\n", m.rotuloPC(pc), m.nomeInst(inst), xLabel[rd], xLabel[rs2], xLabel[rs1], enderecoMem, uint32(x[rs2]), xLabel[rd], uint32(resultado))
+		} else {
+			fmt.Fprintf(writer, "%s:%-7s%s,%s,(%s)   %s=0x%08x\n", m.rotuloPC(pc), m.nomeInst(inst), xLabel[rd], xLabel[rs2], xLabel[rs1], xLabel[rd], uint32(resultado))
+		}
+		// sc.w sempre libera a reserva, com ou sem sucesso
+		m.reservaValida = false
+		if rd != 0 {
+			x[rd] = resultado
+		}
+
+	default: // AMO*.W
+		// Só a RAM aceita AMOs: a leitura de um dispositivo pode consumir
+		// dados (como a FIFO da UART) antes de a escrita falhar
+		if !m.naRAM(enderecoMem, 4) {
+			m.excecao(codigoAcesso, enderecoMem)
+			return
+		}
+		antigo, ok := m.lerDados(enderecoMem, 4)
+		if !ok {
+			m.excecao(codigoAcesso, enderecoMem)
+			return
+		}
+		s1, s2 := int32(antigo), x[rs2]
+		u1, u2 := antigo, uint32(x[rs2])
+
+		var novo uint32
+		switch funct5 {
+		case 0b00001: // amoswap.w
+			novo = u2
+		case 0b00000: // amoadd.w
+			novo = u1 + u2
+		case 0b00100: // amoxor.w
+			novo = u1 ^ u2
+		case 0b01100: // amoand.w
+			novo = u1 & u2
+		case 0b01000: // amoor.w
+			novo = u1 | u2
+		case 0b10000: // amomin.w
+			novo = uint32(min(s1, s2))
+		case 0b10100: // amomax.w
+			novo = uint32(max(s1, s2))
+		case 0b11000: // amominu.w
+			novo = min(u1, u2)
+		case 0b11100: // amomaxu.w
+			novo = max(u1, u2)
+		}
+
+		if !m.escreverDados(enderecoMem, 4, novo) {
+			m.excecao(codigoAcesso, enderecoMem)
+			return
+		}
+		fmt.Fprintf(writer, "%s:%-7s%s,%s,(%s)   %s=mem[0x%08x]=0x%08x,mem[0x%08x]=0x%08x\n", m.rotuloPC(pc), m.nomeInst(inst), xLabel[rd], xLabel[rs2], xLabel[rs1], xLabel[rd], enderecoMem, antigo, enderecoMem, novo)
+		if rd != 0 {
+			x[rd] = int32(antigo)
+		}
+	}
+}
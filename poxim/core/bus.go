@@ -0,0 +1,132 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Device é um periférico mapeado no barramento. offset é relativo à base
+// da região do dispositivo e tam é 1, 2 ou 4 bytes. Retornar false gera a
+// falha de acesso correspondente (load/store fault).
+type Device interface {
+	Read(offset uint32, tam int) (uint32, bool)
+	Write(offset uint32, tam int, valor uint32) bool
+}
+
+// Ticker é implementado por dispositivos que avançam a cada instrução
+// retirada, como o temporizador do CLINT.
+type Ticker interface {
+	Tick()
+}
+
+// regiao é uma faixa de endereços [base, base+tam) atendida por um
+// dispositivo.
+type regiao struct {
+	nome string
+	base uint32
+	tam  uint32
+	dev  Device
+}
+
+// Bus roteia os acessos à memória para o dispositivo dono do endereço.
+// Acessos a endereços não mapeados, ou que cruzam o fim de uma região,
+// falham.
+type Bus struct {
+	regioes []regiao
+	tickers []Ticker
+}
+
+// Attach mapeia dev na faixa [base, base+tam). Regiões não podem se
+// sobrepor.
+func (b *Bus) Attach(nome string, base, tam uint32, dev Device) error {
+	if tam == 0 || base+tam-1 < base {
+		return fmt.Errorf("região inválida para %s: base=0x%08x tam=0x%x", nome, base, tam)
+	}
+	for _, r := range b.regioes {
+		if base <= r.base+r.tam-1 && r.base <= base+tam-1 {
+			return fmt.Errorf("região de %s (0x%08x) sobrepõe %s (0x%08x)", nome, base, r.nome, r.base)
+		}
+	}
+	b.regioes = append(b.regioes, regiao{nome: nome, base: base, tam: tam, dev: dev})
+	if t, ok := dev.(Ticker); ok {
+		b.tickers = append(b.tickers, t)
+	}
+	return nil
+}
+
+// tick avança os dispositivos temporizados após uma instrução retirada.
+func (b *Bus) tick() {
+	for _, t := range b.tickers {
+		t.Tick()
+	}
+}
+
+// buscar retorna a região que contém todo o acesso de tam bytes em
+// endereco, ou nil.
+func (b *Bus) buscar(endereco, tam uint32) *regiao {
+	for i := range b.regioes {
+		r := &b.regioes[i]
+		if endereco >= r.base && endereco-r.base+tam <= r.tam {
+			return r
+		}
+	}
+	return nil
+}
+
+// Mapped indica se o acesso de tam bytes em endereco cai inteiro em uma
+// região.
+func (b *Bus) Mapped(endereco, tam uint32) bool {
+	return b.buscar(endereco, tam) != nil
+}
+
+// Read lê tam bytes em endereco.
+func (b *Bus) Read(endereco, tam uint32) (uint32, bool) {
+	r := b.buscar(endereco, tam)
+	if r == nil {
+		return 0, false
+	}
+	return r.dev.Read(endereco-r.base, int(tam))
+}
+
+// Write escreve tam bytes de valor em endereco.
+func (b *Bus) Write(endereco, tam, valor uint32) bool {
+	r := b.buscar(endereco, tam)
+	if r == nil {
+		return false
+	}
+	return r.dev.Write(endereco-r.base, int(tam), valor)
+}
+
+// RAM é a memória principal, um dispositivo como outro qualquer no
+// barramento.
+type RAM struct {
+	Data []byte
+}
+
+// Read lê tam bytes em little-endian.
+func (r *RAM) Read(offset uint32, tam int) (uint32, bool) {
+	switch tam {
+	case 1:
+		return uint32(r.Data[offset]), true
+	case 2:
+		return uint32(binary.LittleEndian.Uint16(r.Data[offset : offset+2])), true
+	case 4:
+		return binary.LittleEndian.Uint32(r.Data[offset : offset+4]), true
+	}
+	return 0, false
+}
+
+// Write escreve tam bytes em little-endian.
+func (r *RAM) Write(offset uint32, tam int, valor uint32) bool {
+	switch tam {
+	case 1:
+		r.Data[offset] = byte(valor)
+	case 2:
+		binary.LittleEndian.PutUint16(r.Data[offset:offset+2], uint16(valor))
+	case 4:
+		binary.LittleEndian.PutUint32(r.Data[offset:offset+4], valor)
+	default:
+		return false
+	}
+	return true
+}
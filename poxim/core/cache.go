@@ -0,0 +1,508 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Geometria padrão das caches
+const (
+	CACHE_SIZE    = 256 // 256 bytes
+	BLOCK_SIZE    = 16  // 4 palavras de 4 bytes = 16 bytes
+	ASSOCIATIVITY = 2   // Grau de associatividade
+)
+
+// CacheConfig descreve a geometria de uma cache: tamanho total e do bloco
+// em bytes e o número de vias por conjunto. Assoc 1 é mapeamento direto e
+// Assoc 0 (ou Size/BlockSize) é totalmente associativa.
+type CacheConfig struct {
+	Size      int
+	BlockSize int
+	Assoc     int
+
+	// Policy é a política de substituição (lru, fifo, random, plru ou
+	// lfu). Vazia, usa LRU e mantém o log de miss original, sem a vítima.
+	Policy string
+	// Seed é a semente da política random.
+	Seed int64
+
+	// WriteBack troca a escrita direta sem alocação (write-through,
+	// no-write-allocate) por write-back com alocação na escrita: blocos
+	// modificados ficam sujos e só vão à memória quando substituídos ou no
+	// esvaziamento final.
+	WriteBack bool
+}
+
+// DefaultCacheConfig retorna a geometria original da v3: 256 bytes, blocos
+// de 16 bytes e 2 vias.
+func DefaultCacheConfig() CacheConfig {
+	return CacheConfig{Size: CACHE_SIZE, BlockSize: BLOCK_SIZE, Assoc: ASSOCIATIVITY}
+}
+
+// ParseCacheConfig lê uma geometria no formato "tamanho:bloco:vias", em
+// que vias pode ser "full" para uma cache totalmente associativa.
+func ParseCacheConfig(texto string) (CacheConfig, error) {
+	campos := strings.Split(texto, ":")
+	if len(campos) != 3 {
+		return CacheConfig{}, fmt.Errorf("geometria de cache inválida %q, esperado tamanho:bloco:vias", texto)
+	}
+	var cfg CacheConfig
+	var err error
+	if cfg.Size, err = strconv.Atoi(campos[0]); err != nil {
+		return CacheConfig{}, fmt.Errorf("tamanho de cache inválido: %s", campos[0])
+	}
+	if cfg.BlockSize, err = strconv.Atoi(campos[1]); err != nil {
+		return CacheConfig{}, fmt.Errorf("tamanho de bloco inválido: %s", campos[1])
+	}
+	if campos[2] != "full" {
+		if cfg.Assoc, err = strconv.Atoi(campos[2]); err != nil {
+			return CacheConfig{}, fmt.Errorf("associatividade inválida: %s", campos[2])
+		}
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate verifica se a geometria é realizável: potências de 2, blocos
+// de ao menos uma palavra e vias que dividem a cache em conjuntos.
+func (c CacheConfig) Validate() error {
+	potencia := func(n int) bool { return n > 0 && n&(n-1) == 0 }
+	switch {
+	case !potencia(c.Size):
+		return fmt.Errorf("tamanho de cache %d não é potência de 2", c.Size)
+	case !potencia(c.BlockSize) || c.BlockSize < 4:
+		return fmt.Errorf("tamanho de bloco %d não é potência de 2 maior ou igual a 4", c.BlockSize)
+	case c.BlockSize > c.Size:
+		return fmt.Errorf("bloco de %d bytes maior que a cache de %d bytes", c.BlockSize, c.Size)
+	case c.Assoc != 0 && (!potencia(c.Assoc) || c.Assoc > c.Size/c.BlockSize):
+		return fmt.Errorf("associatividade %d inválida para %d blocos", c.Assoc, c.Size/c.BlockSize)
+	}
+	if _, err := novaPolitica(c.Policy, c.Seed); err != nil {
+		return err
+	}
+	if vias := c.Assoc; c.Policy == "plru" && (vias == 0 && c.Size/c.BlockSize > 64 || vias > 64) {
+		return fmt.Errorf("plru suporta no máximo 64 vias")
+	}
+	return nil
+}
+
+// Estrutura do conjunto de cache, com uma entrada por via
+type CacheLine struct {
+	valid []bool
+	tag   []uint32
+	age   []uint32 // Para política LRU
+	dirty []bool   // Bloco modificado em relação à memória (write-back)
+	data  [][]uint32
+
+	// Estado da política de substituição: ordem de chegada (fifo),
+	// contagem de acessos (lfu) ou os bits da árvore em estado[0] (plru)
+	estado []uint64
+}
+
+// Estrutura da cache
+type Cache struct {
+	sets       []CacheLine
+	hits       int
+	misses     int
+	accesses   int
+	writebacks int
+
+	// Modelo de tempo: pedidos atendidos e ciclos gastos com eles
+	pedidos int
+	ciclos  uint64
+
+	writeBack bool
+
+	// Geometria derivada de CacheConfig
+	assoc      int
+	blockWords int
+	offsetBits uint32
+	indexBits  uint32
+
+	politica      politicaSubstituicao
+	mostrarVitima bool // Política escolhida explicitamente: vítima no log
+
+	// Vítima escolhida no log de miss, usada pelo carregamento seguinte
+	vitimaIndex     uint32
+	vitimaVia       int
+	vitimaEscolhida bool
+}
+
+// NewCache cria uma cache vazia com a geometria dada, que deve ser válida
+// (ver CacheConfig.Validate).
+func NewCache(cfg CacheConfig) *Cache {
+	if err := cfg.Validate(); err != nil {
+		panic(err)
+	}
+	blocos := cfg.Size / cfg.BlockSize
+	assoc := cfg.Assoc
+	if assoc == 0 {
+		assoc = blocos
+	}
+	politica, _ := novaPolitica(cfg.Policy, cfg.Seed)
+	cache := &Cache{
+		politica:      politica,
+		mostrarVitima: cfg.Policy != "",
+		writeBack:     cfg.WriteBack,
+		sets:          make([]CacheLine, blocos/assoc),
+		assoc:         assoc,
+		blockWords:    cfg.BlockSize / 4,
+		offsetBits:    uint32(bits.TrailingZeros(uint(cfg.BlockSize))),
+		indexBits:     uint32(bits.TrailingZeros(uint(blocos / assoc))),
+	}
+	for i := range cache.sets {
+		cache.sets[i] = CacheLine{
+			valid:  make([]bool, assoc),
+			tag:    make([]uint32, assoc),
+			dirty:  make([]bool, assoc),
+			age:    make([]uint32, assoc),
+			data:   make([][]uint32, assoc),
+			estado: make([]uint64, assoc),
+		}
+		for j := range cache.sets[i].data {
+			cache.sets[i].data[j] = make([]uint32, cache.blockWords)
+		}
+	}
+	initCache(cache)
+	return cache
+}
+
+// Inicializar cache
+func initCache(cache *Cache) {
+	for i := range cache.sets {
+		for j := 0; j < cache.assoc; j++ {
+			cache.sets[i].valid[j] = false
+			cache.sets[i].dirty[j] = false
+			cache.sets[i].tag[j] = 0
+			cache.sets[i].age[j] = 0
+			cache.sets[i].estado[j] = 0
+		}
+	}
+	cache.hits = 0
+	cache.misses = 0
+	cache.accesses = 0
+	cache.writebacks = 0
+	cache.pedidos = 0
+	cache.ciclos = 0
+}
+
+// Hits retorna o número de acertos da cache.
+func (c *Cache) Hits() int { return c.hits }
+
+// Misses retorna o número de faltas da cache.
+func (c *Cache) Misses() int { return c.misses }
+
+// Accesses retorna o número total de acessos à cache.
+func (c *Cache) Accesses() int { return c.accesses }
+
+// Writebacks retorna o número de blocos sujos escritos de volta na
+// memória.
+func (c *Cache) Writebacks() int { return c.writebacks }
+
+// WriteBack indica se a cache usa write-back com alocação na escrita.
+func (c *Cache) WriteBack() bool { return c.writeBack }
+
+// Extrair tag, índice e offset do endereço
+func (c *Cache) extractAddressFields(address uint32) (uint32, uint32, uint32) {
+	tag := address >> (c.indexBits + c.offsetBits)
+	index := (address >> c.offsetBits) & ((1 << c.indexBits) - 1)
+	offset := (address & ((1 << c.offsetBits) - 1)) >> 2 // Deslocamento em palavras
+	return tag, index, offset
+}
+
+// buscarVia retorna a via do conjunto index que guarda tag, ou -1.
+func (c *Cache) buscarVia(tag, index uint32) int {
+	for i := 0; i < c.assoc; i++ {
+		if c.sets[index].valid[i] && c.sets[index].tag[i] == tag {
+			return i
+		}
+	}
+	return -1
+}
+
+// palavraEm retorna a palavra da cache que contém address e se o bloco
+// está sujo, sem contar o acesso nem alterar a política de substituição.
+func (c *Cache) palavraEm(address uint32) (palavra *uint32, suja, ok bool) {
+	tag, index, offset := c.extractAddressFields(address)
+	via := c.buscarVia(tag, index)
+	if via < 0 {
+		return nil, false, false
+	}
+	return &c.sets[index].data[via][offset], c.sets[index].dirty[via], true
+}
+
+// atualizarLRU marca a via como a mais recentemente usada do conjunto.
+func (c *Cache) atualizarLRU(index uint32, via int) {
+	for j := 0; j < c.assoc; j++ {
+		if c.sets[index].age[j] > c.sets[index].age[via] {
+			c.sets[index].age[j]--
+		}
+	}
+	c.sets[index].age[via] = uint32(c.assoc - 1)
+}
+
+// escolherVitima retorna a via a substituir no conjunto index: a última
+// via inválida, se houver, ou a escolha da política.
+func (c *Cache) escolherVitima(index uint32) int {
+	if c.vitimaEscolhida && c.vitimaIndex == index {
+		c.vitimaEscolhida = false
+		return c.vitimaVia
+	}
+	for i := c.assoc - 1; i >= 0; i-- {
+		if !c.sets[index].valid[i] {
+			return i
+		}
+	}
+	return c.politica.vitima(c, index)
+}
+
+// reservarVitima escolhe a vítima no momento do miss, para exibi-la no
+// log, e a guarda para o carregamento do bloco.
+func (c *Cache) reservarVitima(index uint32) int {
+	c.vitimaEscolhida = false
+	via := c.escolherVitima(index)
+	c.vitimaIndex, c.vitimaVia, c.vitimaEscolhida = index, via, true
+	return via
+}
+
+// formatarBloco formata as palavras do bloco na via como {0x..., ...}.
+func (c *Cache) formatarBloco(index uint32, via int) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for k, palavra := range c.sets[index].data[via] {
+		if k > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "0x%08x", palavra)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// formatarConjunto formata os campos de todas as vias do conjunto para o
+// log de miss: valid={...}, age={...}, id={...}.
+func (c *Cache) formatarConjunto(index uint32) string {
+	var valid, age, id strings.Builder
+	for i := 0; i < c.assoc; i++ {
+		if i > 0 {
+			valid.WriteByte(',')
+			age.WriteByte(',')
+			id.WriteByte(',')
+		}
+		fmt.Fprintf(&valid, "%t", c.sets[index].valid[i])
+		fmt.Fprintf(&age, "%d", c.sets[index].age[i])
+		fmt.Fprintf(&id, "0x%06x", c.sets[index].tag[i])
+	}
+	return fmt.Sprintf("valid={%s}, age={%s}, id={%s}", valid.String(), age.String(), id.String())
+}
+
+// logHit registra no rastro um acerto do evento dado (irh, drh, dwh).
+func (m *Machine) logHit(cache *Cache, evento string, address, index uint32, via int, offset uint32) {
+	fmt.Fprintf(m.trace, "#cache_mem:%s 0x%08x    line=%d, age=%d, id=0x%06x, block[%d]=%s\n",
+		evento, address, index, cache.sets[index].age[via], cache.sets[index].tag[via], offset,
+		cache.formatarBloco(index, via))
+}
+
+// logMiss registra no rastro uma falta do evento dado (irm, drm, dwm).
+// Se a falta aloca um bloco e a política foi escolhida explicitamente, a
+// via vítima é acrescentada ao log.
+func (m *Machine) logMiss(cache *Cache, evento string, address, index uint32, aloca bool) {
+	vitima := ""
+	if aloca && cache.mostrarVitima {
+		vitima = fmt.Sprintf(", victim=%d", cache.reservarVitima(index))
+	}
+	fmt.Fprintf(m.trace, "#cache_mem:%s 0x%08x    line=%d, %s%s\n", evento, address, index, cache.formatarConjunto(index), vitima)
+}
+
+// Acessar cache de instruções
+func (m *Machine) accessICache(address uint32) (uint32, bool) {
+	return m.acessarLeitura(m.ICache, "ir", address)
+}
+
+// Acessar cache de dados (leitura)
+func (m *Machine) accessDCacheRead(address uint32) (uint32, bool) {
+	return m.acessarLeitura(m.DCache, "dr", address)
+}
+
+// acessarLeitura procura a palavra em address na cache, registrando o
+// acerto (<prefixo>h) ou a falta (<prefixo>m) no rastro.
+func (m *Machine) acessarLeitura(cache *Cache, prefixo string, address uint32) (uint32, bool) {
+	tag, index, offset := cache.extractAddressFields(address)
+	cache.accesses++
+
+	// Verificar se está na cache
+	if via := cache.buscarVia(tag, index); via >= 0 {
+		// Hit - atualizar idade LRU
+		cache.hits++
+		m.gastar(m.esperaAcerto(cache))
+		cache.atualizarLRU(index, via)
+		cache.politica.acessar(cache, index, via)
+		m.logHit(cache, prefixo+"h", address, index, via, offset)
+		return cache.sets[index].data[via][offset], true
+	}
+
+	// Miss
+	cache.misses++
+	if cache == m.ICache {
+		m.contarEvento(HPM_EVENT_ICACHE_MISS)
+	} else {
+		m.contarEvento(HPM_EVENT_DCACHE_MISS)
+	}
+	m.logMiss(cache, prefixo+"m", address, index, true)
+	return 0, false
+}
+
+// Acessar cache de dados (escrita) de tam bytes a partir de address,
+// sem cruzar a fronteira da palavra
+func (m *Machine) accessDCacheWrite(address, tam, value uint32) {
+	if !m.escreverNaCache(address, tam, value) && m.DCache.writeBack {
+		// Miss com alocação na escrita: carregar o bloco e escrever nele
+		m.loadBlockToCache(m.DCache, address)
+		m.escreverNaCache(address, tam, value)
+	}
+}
+
+// escreverNaCache atualiza os bytes em address se o bloco estiver na
+// cache de dados, preservando o resto da palavra e marcando o bloco sujo
+// em write-back, e registra o acerto ou a falta no rastro.
+func (m *Machine) escreverNaCache(address, tam, value uint32) bool {
+	cache := m.DCache
+	tag, index, offset := cache.extractAddressFields(address)
+	cache.accesses++
+
+	// Verificar se está na cache
+	if via := cache.buscarVia(tag, index); via >= 0 {
+		// Hit - atualizar dado e idade LRU
+		cache.hits++
+		m.gastar(m.esperaAcerto(cache))
+		desloc := 8 * (address & 0x3)
+		mascara := mascaraBytes(tam) << desloc
+		palavra := &cache.sets[index].data[via][offset]
+		*palavra = *palavra&^mascara | value<<desloc&mascara
+		if cache.writeBack {
+			cache.sets[index].dirty[via] = true
+		}
+		cache.atualizarLRU(index, via)
+		cache.politica.acessar(cache, index, via)
+		m.logHit(cache, "dwh", address, index, via, offset)
+		return true
+	}
+
+	// Miss - em escrita direta não há alocação (no write allocate)
+	cache.misses++
+	m.contarEvento(HPM_EVENT_DCACHE_MISS)
+	m.logMiss(cache, "dwm", address, index, cache.writeBack)
+	return false
+}
+
+// escreverDeVolta grava na memória o bloco sujo da via e o marca limpo,
+// registrando o evento dwb no rastro.
+func (m *Machine) escreverDeVolta(cache *Cache, index uint32, via int) {
+	conjunto := &cache.sets[index]
+	blockAddr := conjunto.tag[via]<<(cache.indexBits+cache.offsetBits) | index<<cache.offsetBits
+	for i, palavra := range conjunto.data[via] {
+		wordAddr := blockAddr + uint32(i*4)
+		if m.naRAM(wordAddr, 4) {
+			idxMem := wordAddr - RAMBase
+			binary.LittleEndian.PutUint32(m.Mem[idxMem:idxMem+4], palavra)
+		}
+	}
+	conjunto.dirty[via] = false
+	cache.writebacks++
+	m.gastar(m.cfg.Latencias.Mem)
+	fmt.Fprintf(m.trace, "#cache_mem:dwb 0x%08x    line=%d, id=0x%06x, block=%s\n",
+		blockAddr, index, conjunto.tag[via], cache.formatarBloco(index, via))
+	if m.L2 != nil {
+		m.escreverBlocoNaL2(blockAddr, conjunto.data[via])
+	}
+}
+
+// flushCache escreve de volta todos os blocos sujos da cache.
+func (m *Machine) flushCache(cache *Cache) {
+	for index := range cache.sets {
+		for via := 0; via < cache.assoc; via++ {
+			if cache.sets[index].valid[via] && cache.sets[index].dirty[via] {
+				m.escreverDeVolta(cache, uint32(index), via)
+			}
+		}
+	}
+}
+
+// sincronizarInstrucoes implementa fence.i: escreve de volta os blocos
+// sujos da cache de dados e invalida a cache de instruções, para que as
+// buscas seguintes vejam as escritas feitas pelos stores.
+func (m *Machine) sincronizarInstrucoes() {
+	if m.ICache == nil {
+		return
+	}
+	if m.DCache != nil && m.DCache.writeBack {
+		m.flushCache(m.DCache)
+	}
+	for index := range m.ICache.sets {
+		for via := 0; via < m.ICache.assoc; via++ {
+			m.ICache.sets[index].valid[via] = false
+		}
+	}
+}
+
+// Carregar bloco na cache
+func (m *Machine) loadBlockToCache(cache *Cache, address uint32) {
+	tag, index, _ := cache.extractAddressFields(address)
+	blockAddr := address & ^uint32(cache.blockWords*4-1)
+	conjunto := &cache.sets[index]
+
+	// Encontrar vítima pela política de substituição
+	victim := cache.escolherVitima(index)
+	if conjunto.valid[victim] && conjunto.dirty[victim] {
+		m.escreverDeVolta(cache, index, victim)
+	}
+
+	if cache == m.L2 && m.cfg.L2Inclusiva && conjunto.valid[victim] {
+		m.invalidarCopiasL1(conjunto.tag[victim]<<(cache.indexBits+cache.offsetBits)|index<<cache.offsetBits, uint32(cache.blockWords*4))
+	}
+	conjunto.valid[victim] = false
+
+	if m.L2 != nil && cache != m.L2 {
+		// Carregar bloco da L2
+		m.lerDaL2(blockAddr, conjunto.data[victim])
+	} else {
+		// Carregar bloco da memória
+		m.gastar(m.cfg.Latencias.Mem)
+		for i := 0; i < cache.blockWords; i++ {
+			wordAddr := blockAddr + uint32(i*4)
+			if m.naRAM(wordAddr, 4) {
+				idxMem := wordAddr - RAMBase
+				conjunto.data[victim][i] = binary.LittleEndian.Uint32(m.Mem[idxMem : idxMem+4])
+			}
+		}
+	}
+
+	// Atualizar metadados
+	conjunto.valid[victim] = true
+	conjunto.tag[victim] = tag
+
+	// Atualizar idades LRU
+	for i := 0; i < cache.assoc; i++ {
+		if i != victim && conjunto.valid[i] {
+			if conjunto.age[i] > 0 {
+				conjunto.age[i]--
+			}
+		}
+	}
+	conjunto.age[victim] = uint32(cache.assoc - 1)
+	cache.politica.inserir(cache, index, victim)
+}
+
+// Dump escreve em w o conteúdo de cada conjunto da cache: para cada via,
+// os bits de validade, o tag, a idade LRU e as palavras do bloco.
+func (c *Cache) Dump(w io.Writer) {
+	for i := range c.sets {
+		for j := 0; j < c.assoc; j++ {
+			linha := &c.sets[i]
+			fmt.Fprintf(w, "set %d via %d: valid=%t dirty=%t tag=0x%06x age=%d data=%s\n", i, j, linha.valid[j], linha.dirty[j], linha.tag[j], linha.age[j], c.formatarBloco(uint32(i), j))
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package core
+
+// Extração dos campos e imediatos de uma instrução de 32 bits, comum à
+// execução e ao desmontador.
+
+// campos retorna os campos fixos de uma instrução de 32 bits.
+func campos(instrucao uint32) (opcode, rd, rs1, rs2, funct3, funct7 uint32) {
+	opcode = instrucao & 0x7F
+	rd = (instrucao >> 7) & 0x1F
+	rs1 = (instrucao >> 15) & 0x1F
+	rs2 = (instrucao >> 20) & 0x1F
+	funct3 = (instrucao >> 12) & 0x7
+	funct7 = (instrucao >> 25) & 0x7F
+	return
+}
+
+// campoRs3 retorna rs3 das instruções R4 da extensão F, que é também o
+// funct5 das instruções da extensão A.
+func campoRs3(instrucao uint32) uint32 {
+	return instrucao >> 27
+}
+
+// imediatoI retorna o imediato com sinal do formato I.
+func imediatoI(instrucao uint32) int32 {
+	return estenderSinal(instrucao>>20, 12)
+}
+
+// imediatoS retorna o imediato com sinal do formato S.
+func imediatoS(instrucao uint32) int32 {
+	bitsImmS := ((instrucao>>25)&0x7F)<<5 | ((instrucao >> 7) & 0x1F)
+	return estenderSinal(bitsImmS, 12)
+}
+
+// imediatoB retorna o deslocamento com sinal do formato B.
+func imediatoB(instrucao uint32) int32 {
+	bitsImmB := ((instrucao >> 8) & 0xF) << 1   // imm[4:1]
+	bitsImmB |= ((instrucao >> 25) & 0x3F) << 5 // imm[10:5]
+	bitsImmB |= ((instrucao >> 7) & 1) << 11    // imm[11]
+	bitsImmB |= ((instrucao >> 31) & 1) << 12   // imm[12]
+	return estenderSinal(bitsImmB, 13)
+}
+
+// imediatoJ retorna o deslocamento com sinal do formato J.
+func imediatoJ(instrucao uint32) int32 {
+	bitsImmJ := ((instrucao >> 21) & 0x3FF) << 1 // imm[10:1]
+	bitsImmJ |= ((instrucao >> 20) & 0x1) << 11  // imm[11]
+	bitsImmJ |= ((instrucao >> 12) & 0xFF) << 12 // imm[19:12]
+	bitsImmJ |= ((instrucao >> 31) & 1) << 20    // imm[20]
+	return estenderSinal(bitsImmJ, 21)
+}
@@ -0,0 +1,103 @@
+package core
+
+// Mapa de registradores do CLINT (Core Local Interruptor), compatível com
+// o do SiFive/QEMU virt para um único hart
+const (
+	CLINTBase uint32 = 0x02000000
+	CLINTSize uint32 = 0x10000
+
+	clintMsip     = 0x0000
+	clintMtimecmp = 0x4000
+	clintMtime    = 0xBFF8
+)
+
+// CLINT implementa o temporizador mtime/mtimecmp e o registrador msip.
+// mtime avança uma unidade a cada Razao instruções retiradas; enquanto
+// mtime >= mtimecmp, MTIP fica pendente em mip. msip espelha MSIP.
+type CLINT struct {
+	// Razao é o número de instruções retiradas por incremento de mtime.
+	Razao uint32
+
+	mtime    uint64
+	mtimecmp uint64
+	msip     uint32
+	contador uint32
+
+	irq func(bit uint32, pendente bool)
+}
+
+// NewCLINT cria um CLINT que sinaliza suas interrupções com irq. Uma razao
+// zero equivale a 1. mtimecmp começa no máximo, sem timer armado.
+func NewCLINT(razao uint32, irq func(bit uint32, pendente bool)) *CLINT {
+	if razao == 0 {
+		razao = 1
+	}
+	return &CLINT{Razao: razao, mtimecmp: ^uint64(0), irq: irq}
+}
+
+// Mtime retorna o valor atual de mtime.
+func (c *CLINT) Mtime() uint64 {
+	return c.mtime
+}
+
+// Tick conta uma instrução retirada.
+func (c *CLINT) Tick() {
+	c.contador++
+	if c.contador >= c.Razao {
+		c.contador = 0
+		c.mtime++
+	}
+	c.atualizar()
+}
+
+// atualizar compara mtime com mtimecmp e dirige MTIP. O CLINT é a única
+// fonte de MTIP, então a linha é dirigida a cada chamada.
+func (c *CLINT) atualizar() {
+	c.irq(MIP_MTIP_BIT, c.mtime >= c.mtimecmp)
+}
+
+// Read lê um registrador de 32 bits; os de 64 bits são acessados em
+// metades.
+func (c *CLINT) Read(offset uint32, tam int) (uint32, bool) {
+	if tam != 4 || offset&0x3 != 0 {
+		return 0, false
+	}
+	switch offset {
+	case clintMsip:
+		return c.msip, true
+	case clintMtimecmp:
+		return uint32(c.mtimecmp), true
+	case clintMtimecmp + 4:
+		return uint32(c.mtimecmp >> 32), true
+	case clintMtime:
+		return uint32(c.mtime), true
+	case clintMtime + 4:
+		return uint32(c.mtime >> 32), true
+	}
+	return 0, false
+}
+
+// Write escreve um registrador de 32 bits.
+func (c *CLINT) Write(offset uint32, tam int, valor uint32) bool {
+	if tam != 4 || offset&0x3 != 0 {
+		return false
+	}
+	switch offset {
+	case clintMsip:
+		c.msip = valor & 0x1
+		c.irq(MIP_MSIP_BIT, c.msip != 0)
+		return true
+	case clintMtimecmp:
+		c.mtimecmp = c.mtimecmp&^0xFFFFFFFF | uint64(valor)
+	case clintMtimecmp + 4:
+		c.mtimecmp = c.mtimecmp&0xFFFFFFFF | uint64(valor)<<32
+	case clintMtime:
+		c.mtime = c.mtime&^0xFFFFFFFF | uint64(valor)
+	case clintMtime + 4:
+		c.mtime = c.mtime&0xFFFFFFFF | uint64(valor)<<32
+	default:
+		return false
+	}
+	c.atualizar()
+	return true
+}
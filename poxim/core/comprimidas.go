@@ -0,0 +1,195 @@
+package core
+
+// Expansão das instruções comprimidas (RV32C) para as equivalentes de 32
+// bits. A execução é sempre feita sobre a forma expandida; apenas o nome da
+// instrução comprimida é preservado para o rastro.
+
+// Montadores dos formatos de 32 bits usados na expansão
+func encR(funct7, rs2, rs1, funct3, rd, opcode uint32) uint32 {
+	return funct7<<25 | rs2<<20 | rs1<<15 | funct3<<12 | rd<<7 | opcode
+}
+
+func encI(imm int32, rs1, funct3, rd, opcode uint32) uint32 {
+	return (uint32(imm)&0xFFF)<<20 | rs1<<15 | funct3<<12 | rd<<7 | opcode
+}
+
+func encS(imm int32, rs2, rs1, funct3, opcode uint32) uint32 {
+	u := uint32(imm)
+	return ((u>>5)&0x7F)<<25 | rs2<<20 | rs1<<15 | funct3<<12 | (u&0x1F)<<7 | opcode
+}
+
+func encB(imm int32, rs2, rs1, funct3 uint32) uint32 {
+	u := uint32(imm)
+	return ((u>>12)&1)<<31 | ((u>>5)&0x3F)<<25 | rs2<<20 | rs1<<15 | funct3<<12 |
+		((u>>1)&0xF)<<8 | ((u>>11)&1)<<7 | 0b1100011
+}
+
+func encJ(imm int32, rd uint32) uint32 {
+	u := uint32(imm)
+	return ((u>>20)&1)<<31 | ((u>>1)&0x3FF)<<21 | ((u>>11)&1)<<20 | ((u>>12)&0xFF)<<12 | rd<<7 | 0b1101111
+}
+
+// bit extrai o bit n da instrução comprimida e o posiciona em destino.
+func bit(c uint32, n, destino uint) uint32 {
+	return ((c >> n) & 1) << destino
+}
+
+// expandirComprimida converte a instrução comprimida c na instrução de 32
+// bits equivalente. Retorna também o mnemônico comprimido e false se a
+// codificação for ilegal ou reservada.
+func expandirComprimida(c uint32) (uint32, string, bool) {
+	if c == 0 {
+		return 0, "", false // Instrução ilegal por definição
+	}
+
+	funct3 := (c >> 13) & 0x7
+	rdL := (c >> 7) & 0x1F       // rd/rs1 completo
+	rs2L := (c >> 2) & 0x1F      // rs2 completo
+	rdC := ((c >> 2) & 0x7) + 8  // rd'/rs2'
+	rs1C := ((c >> 7) & 0x7) + 8 // rs1'/rd'
+
+	// Imediato de 6 bits com sinal: imm[5]=c[12], imm[4:0]=c[6:2]
+	imm6 := estenderSinal(bit(c, 12, 5)|(c>>2)&0x1F, 6)
+
+	switch c & 0x3 {
+	case 0b00: // Quadrante 0
+		// uimm[5:3]=c[12:10], uimm[2]=c[6], uimm[6]=c[5]
+		uimmW := int32(((c>>10)&0x7)<<3 | bit(c, 6, 2) | bit(c, 5, 6))
+		switch funct3 {
+		case 0b000: // c.addi4spn
+			nzuimm := ((c>>11)&0x3)<<4 | ((c>>7)&0xF)<<6 | bit(c, 6, 2) | bit(c, 5, 3)
+			if nzuimm == 0 {
+				return 0, "", false
+			}
+			return encI(int32(nzuimm), 2, 0b000, rdC, 0b0010011), "c.addi4spn", true
+		case 0b010: // c.lw
+			return encI(uimmW, rs1C, 0b010, rdC, 0b0000011), "c.lw", true
+		case 0b011: // c.flw
+			return encI(uimmW, rs1C, 0b010, rdC, 0b0000111), "c.flw", true
+		case 0b110: // c.sw
+			return encS(uimmW, rdC, rs1C, 0b010, 0b0100011), "c.sw", true
+		case 0b111: // c.fsw
+			return encS(uimmW, rdC, rs1C, 0b010, 0b0100111), "c.fsw", true
+		}
+
+	case 0b01: // Quadrante 1
+		switch funct3 {
+		case 0b000: // c.addi / c.nop
+			if rdL == 0 {
+				return encI(0, 0, 0b000, 0, 0b0010011), "c.nop", true
+			}
+			return encI(imm6, rdL, 0b000, rdL, 0b0010011), "c.addi", true
+		case 0b001, 0b101: // c.jal / c.j
+			// offset[11|4|9:8|10|6|7|3:1|5] = c[12:2]
+			bits := bit(c, 12, 11) | bit(c, 11, 4) | ((c>>9)&0x3)<<8 | bit(c, 8, 10) |
+				bit(c, 7, 6) | bit(c, 6, 7) | ((c>>3)&0x7)<<1 | bit(c, 2, 5)
+			offset := estenderSinal(bits, 12)
+			if funct3 == 0b001 {
+				return encJ(offset, 1), "c.jal", true
+			}
+			return encJ(offset, 0), "c.j", true
+		case 0b010: // c.li
+			return encI(imm6, 0, 0b000, rdL, 0b0010011), "c.li", true
+		case 0b011:
+			if rdL == 2 { // c.addi16sp
+				// nzimm[9]=c[12], nzimm[4|6|8:7|5]=c[6:2]
+				bits := bit(c, 12, 9) | bit(c, 6, 4) | bit(c, 5, 6) | ((c>>3)&0x3)<<7 | bit(c, 2, 5)
+				if bits == 0 {
+					return 0, "", false
+				}
+				return encI(estenderSinal(bits, 10), 2, 0b000, 2, 0b0010011), "c.addi16sp", true
+			}
+			// c.lui: nzimm[17]=c[12], nzimm[16:12]=c[6:2]
+			if imm6 == 0 {
+				return 0, "", false
+			}
+			return uint32(imm6)<<12 | rdL<<7 | 0b0110111, "c.lui", true
+		case 0b100: // Operações lógicas e aritméticas
+			shamt := (c >> 2) & 0x1F
+			switch (c >> 10) & 0x3 {
+			case 0b00: // c.srli
+				if bit(c, 12, 0) != 0 {
+					return 0, "", false // shamt[5] reservado em RV32
+				}
+				return encI(int32(shamt), rs1C, 0b101, rs1C, 0b0010011), "c.srli", true
+			case 0b01: // c.srai
+				if bit(c, 12, 0) != 0 {
+					return 0, "", false
+				}
+				return encI(int32(0b0100000<<5|shamt), rs1C, 0b101, rs1C, 0b0010011), "c.srai", true
+			case 0b10: // c.andi
+				return encI(imm6, rs1C, 0b111, rs1C, 0b0010011), "c.andi", true
+			case 0b11:
+				if bit(c, 12, 0) != 0 {
+					return 0, "", false // subw/addw só existem em RV64
+				}
+				switch (c >> 5) & 0x3 {
+				case 0b00: // c.sub
+					return encR(0b0100000, rdC, rs1C, 0b000, rs1C, 0b0110011), "c.sub", true
+				case 0b01: // c.xor
+					return encR(0, rdC, rs1C, 0b100, rs1C, 0b0110011), "c.xor", true
+				case 0b10: // c.or
+					return encR(0, rdC, rs1C, 0b110, rs1C, 0b0110011), "c.or", true
+				case 0b11: // c.and
+					return encR(0, rdC, rs1C, 0b111, rs1C, 0b0110011), "c.and", true
+				}
+			}
+		case 0b110, 0b111: // c.beqz / c.bnez
+			// offset[8|4:3]=c[12:10], offset[7:6|2:1|5]=c[6:2]
+			bits := bit(c, 12, 8) | ((c>>10)&0x3)<<3 | ((c>>5)&0x3)<<6 | ((c>>3)&0x3)<<1 | bit(c, 2, 5)
+			offset := estenderSinal(bits, 9)
+			if funct3 == 0b110 {
+				return encB(offset, 0, rs1C, 0b000), "c.beqz", true
+			}
+			return encB(offset, 0, rs1C, 0b001), "c.bnez", true
+		}
+
+	case 0b10: // Quadrante 2
+		switch funct3 {
+		case 0b000: // c.slli
+			if bit(c, 12, 0) != 0 {
+				return 0, "", false
+			}
+			return encI(int32(rs2L), rdL, 0b001, rdL, 0b0010011), "c.slli", true
+		case 0b010, 0b011: // c.lwsp / c.flwsp
+			// uimm[5]=c[12], uimm[4:2]=c[6:4], uimm[7:6]=c[3:2]
+			uimm := int32(bit(c, 12, 5) | ((c>>4)&0x7)<<2 | ((c>>2)&0x3)<<6)
+			if funct3 == 0b011 {
+				return encI(uimm, 2, 0b010, rdL, 0b0000111), "c.flwsp", true
+			}
+			if rdL == 0 {
+				return 0, "", false
+			}
+			return encI(uimm, 2, 0b010, rdL, 0b0000011), "c.lwsp", true
+		case 0b100:
+			if bit(c, 12, 0) == 0 {
+				if rs2L == 0 { // c.jr
+					if rdL == 0 {
+						return 0, "", false
+					}
+					return encI(0, rdL, 0b000, 0, 0b1100111), "c.jr", true
+				}
+				// c.mv
+				return encR(0, rs2L, 0, 0b000, rdL, 0b0110011), "c.mv", true
+			}
+			if rdL == 0 && rs2L == 0 { // c.ebreak
+				return 0x00100073, "c.ebreak", true
+			}
+			if rs2L == 0 { // c.jalr
+				return encI(0, rdL, 0b000, 1, 0b1100111), "c.jalr", true
+			}
+			// c.add
+			return encR(0, rs2L, rdL, 0b000, rdL, 0b0110011), "c.add", true
+		case 0b110, 0b111: // c.swsp / c.fswsp
+			// uimm[5:2]=c[12:9], uimm[7:6]=c[8:7]
+			uimm := int32(((c>>9)&0xF)<<2 | ((c>>7)&0x3)<<6)
+			if funct3 == 0b111 {
+				return encS(uimm, rs2L, 2, 0b010, 0b0100111), "c.fswsp", true
+			}
+			return encS(uimm, rs2L, 2, 0b010, 0b0100011), "c.swsp", true
+		}
+	}
+
+	// c.fld, c.fsd, c.fldsp e c.fsdsp dependem da extensão D
+	return 0, "", false
+}
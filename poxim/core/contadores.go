@@ -0,0 +1,174 @@
+package core
+
+import "fmt"
+
+// Contadores de desempenho (Zicntr/Zihpm). mcycle, minstret e
+// mhpmcounter3..31 ficam em m.contadores, indexados pelos 5 bits baixos do
+// endereço do CSR; as metades altas (…h) e os apelidos de usuário
+// (cycle, time, instret, hpmcounterN) são visões do mesmo contador. Os
+// apelidos de usuário são somente leitura.
+//
+// mcycle conta um ciclo por instrução ou, com o modelo de tempo, os ciclos
+// do modelo. minstret conta as instruções que terminam sem trap. Cada
+// mhpmcounterN conta o evento selecionado em mhpmeventN. Os bits de
+// mcountinhibit (CY = 0, IR = 2, HPMn = n) congelam os contadores.
+
+// Endereços dos CSRs de contadores
+const (
+	MCOUNTINHIBIT = 0x320
+	MHPMEVENT3    = 0x323
+	MHPMEVENT31   = 0x33F
+	MINSTRET      = 0xB02
+	MHPMCOUNTER3  = 0xB03
+	MINSTRETH     = 0xB82
+	CYCLE         = 0xC00
+	TIME          = 0xC01
+	INSTRET       = 0xC02
+	CYCLEH        = 0xC80
+	TIMEH         = 0xC81
+	INSTRETH      = 0xC82
+)
+
+// Índices de mcycle e minstret em m.contadores
+const (
+	contadorCiclos     = 0
+	contadorInstrucoes = 2
+)
+
+// Eventos selecionáveis em mhpmevent3..31
+const (
+	HPM_EVENT_NONE         = 0
+	HPM_EVENT_ICACHE_MISS  = 1 // Falta na cache de instruções
+	HPM_EVENT_DCACHE_MISS  = 2 // Falta na cache de dados (leitura ou escrita)
+	HPM_EVENT_TAKEN_BRANCH = 3 // Desvio condicional tomado
+	HPM_EVENT_BRANCH       = 4 // Desvio condicional executado
+	HPM_EVENT_MISPREDICT   = 5 // Desvio ou salto previsto errado
+	HPM_EVENT_L2_MISS      = 6 // Falta na L2
+	numEventosHPM          = 7
+)
+
+func init() {
+	for nome, endereco := range map[string]uint32{
+		"minstret": MINSTRET, "minstreth": MINSTRETH, "mcountinhibit": MCOUNTINHIBIT,
+		"cycle": CYCLE, "time": TIME, "instret": INSTRET,
+		"cycleh": CYCLEH, "timeh": TIMEH, "instreth": INSTRETH,
+	} {
+		csrNames[nome] = endereco
+	}
+	for n := uint32(3); n < 32; n++ {
+		csrNames[fmt.Sprintf("mhpmcounter%d", n)] = 0xB00 + n
+		csrNames[fmt.Sprintf("mhpmcounter%dh", n)] = 0xB80 + n
+		csrNames[fmt.Sprintf("hpmcounter%d", n)] = 0xC00 + n
+		csrNames[fmt.Sprintf("hpmcounter%dh", n)] = 0xC80 + n
+		csrNames[fmt.Sprintf("mhpmevent%d", n)] = MHPMEVENT3 + n - 3
+	}
+}
+
+// ehContador indica se endereco é um CSR de contador, com o índice do
+// contador, se é a metade alta e se é o apelido de usuário.
+func ehContador(endereco uint32) (indice uint32, alta, usuario, ok bool) {
+	indice = endereco & 0x1F
+	switch endereco &^ 0x1F {
+	case 0xB00:
+	case 0xB80:
+		alta = true
+	case 0xC00:
+		usuario = true
+	case 0xC80:
+		alta, usuario = true, true
+	default:
+		return 0, false, false, false
+	}
+	if indice == 1 && !usuario {
+		return 0, false, false, false // mtime não é um CSR
+	}
+	return indice, alta, usuario, true
+}
+
+// lerContador lê um CSR de contador ou de configuração dos contadores.
+func (m *Machine) lerContador(endereco uint32) (uint32, bool) {
+	if indice, alta, _, ok := ehContador(endereco); ok {
+		valor := m.contadores[indice]
+		if indice == 1 { // time
+			if m.CLINT == nil {
+				return 0, true
+			}
+			valor = m.CLINT.Mtime()
+		}
+		if alta {
+			return uint32(valor >> 32), true
+		}
+		return uint32(valor), true
+	}
+	switch {
+	case endereco == MCOUNTINHIBIT:
+		return m.inibidos, true
+	case endereco >= MHPMEVENT3 && endereco <= MHPMEVENT31:
+		return m.eventosHPM[endereco-MHPMEVENT3+3], true
+	}
+	return 0, false
+}
+
+// escreverContador escreve um CSR de contador ou de configuração dos
+// contadores. Os apelidos de usuário, somente leitura, não são alterados.
+func (m *Machine) escreverContador(endereco, valor uint32) bool {
+	if indice, alta, usuario, ok := ehContador(endereco); ok {
+		if usuario {
+			return true
+		}
+		c := &m.contadores[indice]
+		if alta {
+			*c = *c&0xFFFFFFFF | uint64(valor)<<32
+		} else {
+			*c = *c&^0xFFFFFFFF | uint64(valor)
+		}
+		m.contadoresEscritos |= 1 << indice
+		return true
+	}
+	switch {
+	case endereco == MCOUNTINHIBIT:
+		m.inibidos = valor &^ 0x2 // Bit 1 (TM) é fixo em zero
+		m.atualizarEventosHPM()
+	case endereco >= MHPMEVENT3 && endereco <= MHPMEVENT31:
+		m.eventosHPM[endereco-MHPMEVENT3+3] = valor
+		m.atualizarEventosHPM()
+	default:
+		return false
+	}
+	return true
+}
+
+// atualizarEventosHPM recalcula, para cada evento, a máscara dos
+// contadores habilitados que o contam.
+func (m *Machine) atualizarEventosHPM() {
+	clear(m.contadoresPorEvento[:])
+	for n := 3; n < 32; n++ {
+		if evento := m.eventosHPM[n]; evento != HPM_EVENT_NONE && evento < numEventosHPM && m.inibidos&(1<<n) == 0 {
+			m.contadoresPorEvento[evento] |= 1 << n
+		}
+	}
+}
+
+// contarEvento incrementa os contadores programados para o evento.
+func (m *Machine) contarEvento(evento int) {
+	mascara := m.contadoresPorEvento[evento]
+	for n := 3; mascara != 0 && n < 32; n++ {
+		if mascara&(1<<n) != 0 {
+			m.contadores[n]++
+			mascara &^= 1 << n
+		}
+	}
+}
+
+// avancarContadores soma os ciclos da instrução a mcycle e, se ela
+// terminou sem trap, uma instrução a minstret. Contadores escritos pela
+// própria instrução não avançam.
+func (m *Machine) avancarContadores(ciclos uint64, retirada bool) {
+	if m.inibidos&(1<<contadorCiclos) == 0 && m.contadoresEscritos&(1<<contadorCiclos) == 0 {
+		m.contadores[contadorCiclos] += ciclos
+	}
+	if retirada && m.inibidos&(1<<contadorInstrucoes) == 0 && m.contadoresEscritos&(1<<contadorInstrucoes) == 0 {
+		m.contadores[contadorInstrucoes]++
+	}
+	m.contadoresEscritos = 0
+}
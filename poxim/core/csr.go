@@ -0,0 +1,321 @@
+package core
+
+// Constantes para os endereços dos CSRs
+const (
+	FFLAGS     = 0x001
+	FRM        = 0x002
+	FCSR       = 0x003
+	SSTATUS    = 0x100
+	SIE        = 0x104
+	STVEC      = 0x105
+	SCOUNTEREN = 0x106
+	SSCRATCH   = 0x140
+	SEPC       = 0x141
+	SCAUSE     = 0x142
+	STVAL      = 0x143
+	SIP        = 0x144
+	SATP       = 0x180
+	MSTATUS    = 0x300
+	MISA       = 0x301
+	MEDELEG    = 0x302
+	MIDELEG    = 0x303
+	MIE        = 0x304
+	MTVEC      = 0x305
+	MCOUNTEREN = 0x306
+	MSCRATCH   = 0x340
+	MEPC       = 0x341
+	MCAUSE     = 0x342
+	MTVAL      = 0x343
+	MIP        = 0x344
+	MCYCLE     = 0xB00
+	MCYCLEH    = 0xB80
+	MVENDORID  = 0xF11
+	MARCHID    = 0xF12
+	MIMPID     = 0xF13
+	MHARTID    = 0xF14
+)
+
+// Constantes para os bits dos CSRs
+const (
+	MSTATUS_SIE_BIT  = 1 << 1
+	MSTATUS_MIE_BIT  = 1 << 3
+	MSTATUS_SPIE_BIT = 1 << 5
+	MSTATUS_MPIE_BIT = 1 << 7
+	MSTATUS_SPP      = 1 << 8
+	MSTATUS_MPP      = 0x3 << 11
+	MSTATUS_FS       = 0x3 << 13 // Estado da FPU: Off, Initial, Clean, Dirty
+	MSTATUS_SD       = 1 << 31   // FS em Dirty
+	MSTATUS_FS_INIT  = 1 << 13
+	MIP_SSIP_BIT     = 1 << 1
+	MIP_STIP_BIT     = 1 << 5
+	MIP_SEIP_BIT     = 1 << 9
+	MTVEC_MODE       = 0x3
+	MTVEC_VETORIZADO = 1
+	MIP_MTIP_BIT     = 1 << 7
+	MIP_MSIP_BIT     = 1 << 3
+	MIP_MEIP_BIT     = 1 << 11
+)
+
+// Constantes para os códigos de exceção
+const (
+	EXC_INSTRUCTION_ACCESS_FAULT = 1
+	EXC_ILLEGAL_INSTRUCTION      = 2
+	EXC_LOAD_ADDRESS_MISALIGNED  = 4
+	EXC_LOAD_ACCESS_FAULT        = 5
+	EXC_STORE_ADDRESS_MISALIGNED = 6
+	EXC_STORE_ACCESS_FAULT       = 7
+	EXC_ECALL_FROM_U_MODE        = 8
+	EXC_ECALL_FROM_S_MODE        = 9
+	EXC_ECALL_FROM_M_MODE        = 11
+)
+
+// Constantes para os códigos de interrupção
+const (
+	INT_SUPERVISOR_SOFTWARE = 1
+	INT_MACHINE_SOFTWARE    = 3
+	INT_SUPERVISOR_TIMER    = 5
+	INT_MACHINE_TIMER       = 7
+	INT_SUPERVISOR_EXTERNAL = 9
+	INT_MACHINE_EXTERNAL    = 11
+)
+
+// Mapa de nomes de exceções
+var exceptionNames = map[uint32]string{
+	EXC_INSTRUCTION_ACCESS_FAULT: "instruction_fault",
+	EXC_ILLEGAL_INSTRUCTION:      "illegal_instruction",
+	EXC_LOAD_ADDRESS_MISALIGNED:  "load_misaligned",
+	EXC_LOAD_ACCESS_FAULT:        "load_fault",
+	EXC_STORE_ADDRESS_MISALIGNED: "store_misaligned",
+	EXC_STORE_ACCESS_FAULT:       "store_fault",
+	EXC_ECALL_FROM_U_MODE:        "environment_call",
+	EXC_ECALL_FROM_S_MODE:        "environment_call",
+	EXC_ECALL_FROM_M_MODE:        "environment_call",
+}
+
+// Níveis de privilégio, na codificação de mstatus.MPP
+const (
+	PRIV_U = 0
+	PRIV_S = 1
+	PRIV_M = 3
+)
+
+// Mapa de nomes dos níveis de privilégio, usados no rastro
+var privilegeNames = map[uint32]string{
+	PRIV_U: "user",
+	PRIV_S: "supervisor",
+	PRIV_M: "machine",
+}
+
+// Mapa de nomes de interrupções
+var interruptNames = map[uint32]string{
+	INT_SUPERVISOR_SOFTWARE: "supervisor_software",
+	INT_MACHINE_SOFTWARE:    "software",
+	INT_SUPERVISOR_TIMER:    "supervisor_timer",
+	INT_MACHINE_TIMER:       "timer",
+	INT_SUPERVISOR_EXTERNAL: "supervisor_external",
+	INT_MACHINE_EXTERNAL:    "external",
+}
+
+// Interrupções em ordem de prioridade
+var prioridadeInterrupcoes = []uint32{
+	INT_MACHINE_EXTERNAL, INT_MACHINE_SOFTWARE, INT_MACHINE_TIMER,
+	INT_SUPERVISOR_EXTERNAL, INT_SUPERVISOR_SOFTWARE, INT_SUPERVISOR_TIMER,
+}
+
+// Mapa de nomes de CSRs, usado pelo depurador
+var csrNames = map[string]uint32{
+	"fflags":     FFLAGS,
+	"frm":        FRM,
+	"fcsr":       FCSR,
+	"sstatus":    SSTATUS,
+	"sie":        SIE,
+	"stvec":      STVEC,
+	"scounteren": SCOUNTEREN,
+	"sscratch":   SSCRATCH,
+	"sepc":       SEPC,
+	"scause":     SCAUSE,
+	"stval":      STVAL,
+	"sip":        SIP,
+	"satp":       SATP,
+	"mstatus":    MSTATUS,
+	"misa":       MISA,
+	"medeleg":    MEDELEG,
+	"mideleg":    MIDELEG,
+	"mie":        MIE,
+	"mtvec":      MTVEC,
+	"mcounteren": MCOUNTEREN,
+	"mscratch":   MSCRATCH,
+	"mepc":       MEPC,
+	"mcause":     MCAUSE,
+	"mtval":      MTVAL,
+	"mip":        MIP,
+	"mcycle":     MCYCLE,
+	"mcycleh":    MCYCLEH,
+	"mvendorid":  MVENDORID,
+	"marchid":    MARCHID,
+	"mimpid":     MIMPID,
+	"mhartid":    MHARTID,
+}
+
+// registroCSR descreve um CSR guardado em m.CSR. Escritas só alteram os
+// bits de mascara; os demais mantêm o valor (fixo, para os campos
+// somente leitura). legalizar, se definida, corrige os campos WARL do
+// valor resultante, dado o valor anterior.
+type registroCSR struct {
+	mascara   uint32
+	legalizar func(m *Machine, antigo, novo uint32) uint32
+}
+
+// Registro dos CSRs de máquina implementados, além de fflags, frm, fcsr e
+// dos contadores (ver contadores.go). Os endereços com os bits 11:10 em 11
+// são somente leitura: escrevê-los gera instrução ilegal.
+var registrosCSR = map[uint32]registroCSR{
+	MSTATUS:    {mascara: MSTATUS_MIE_BIT | MSTATUS_MPIE_BIT | MSTATUS_MPP | mascaraSstatus, legalizar: legalizarMstatus},
+	MISA:       {}, // Escritas ignoradas
+	MEDELEG:    {mascara: 0xFFFF &^ (1 << EXC_ECALL_FROM_M_MODE)},
+	MIDELEG:    {mascara: interrupcoesSupervisor},
+	MIE:        {mascara: MIP_MSIP_BIT | MIP_MTIP_BIT | MIP_MEIP_BIT | interrupcoesSupervisor},
+	MTVEC:      {mascara: 0xFFFFFFFF, legalizar: legalizarMtvec},
+	MCOUNTEREN: {mascara: 0xFFFFFFFF},
+	MSCRATCH:   {mascara: 0xFFFFFFFF},
+	MEPC:       {mascara: 0xFFFFFFFE}, // Alinhado a 2 bytes (RV32C)
+	MCAUSE:     {mascara: 0xFFFFFFFF},
+	MTVAL:      {mascara: 0xFFFFFFFF},
+	MIP:        {mascara: interrupcoesSupervisor}, // Os bits de máquina vêm do CLINT e dos dispositivos
+	STVEC:      {mascara: 0xFFFFFFFF, legalizar: legalizarMtvec},
+	SCOUNTEREN: {mascara: 0xFFFFFFFF},
+	SSCRATCH:   {mascara: 0xFFFFFFFF},
+	SEPC:       {mascara: 0xFFFFFFFE},
+	SCAUSE:     {mascara: 0xFFFFFFFF},
+	STVAL:      {mascara: 0xFFFFFFFF},
+	SATP:       {}, // Sem tradução de endereços: sempre Bare
+	MVENDORID:  {},
+	MARCHID:    {},
+	MIMPID:     {},
+	MHARTID:    {},
+}
+
+// Bits de mstatus visíveis em sstatus e interrupções de supervisor em mip
+const (
+	mascaraSstatus         = MSTATUS_SIE_BIT | MSTATUS_SPIE_BIT | MSTATUS_SPP | MSTATUS_FS | MSTATUS_SD
+	interrupcoesSupervisor = MIP_SSIP_BIT | MIP_STIP_BIT | MIP_SEIP_BIT
+)
+
+// visaoCSR descreve um CSR de supervisor que é uma visão restrita de um
+// CSR de máquina: lê os bits de leitura e escreve os de escrita de base.
+type visaoCSR struct {
+	base    uint32
+	mascara func(m *Machine) (leitura, escrita uint32)
+}
+
+// Visões de supervisor; sie e sip só alcançam as interrupções delegadas
+// em mideleg e, em sip, apenas SSIP é gravável.
+var visoesCSR = map[uint32]visaoCSR{
+	SSTATUS: {MSTATUS, func(*Machine) (uint32, uint32) { return mascaraSstatus, mascaraSstatus }},
+	SIE:     {MIE, func(m *Machine) (uint32, uint32) { return m.CSR[MIDELEG], m.CSR[MIDELEG] }},
+	SIP:     {MIP, func(m *Machine) (uint32, uint32) { return m.CSR[MIDELEG], m.CSR[MIDELEG] & MIP_SSIP_BIT }},
+}
+
+// legalizarMstatus mantém o MPP anterior se o escrito não for um nível
+// de privilégio implementado, fixa FS em Off sem a extensão F e recalcula
+// SD, somente leitura.
+func legalizarMstatus(m *Machine, antigo, novo uint32) uint32 {
+	if _, ok := privilegeNames[(novo&MSTATUS_MPP)>>11]; !ok {
+		novo = novo&^MSTATUS_MPP | antigo&MSTATUS_MPP
+	}
+	if !m.cfg.ExtF {
+		novo &^= MSTATUS_FS
+	}
+	return comSD(novo)
+}
+
+// comSD define o bit SD de mstatus a partir de FS.
+func comSD(mstatus uint32) uint32 {
+	if mstatus&MSTATUS_FS == MSTATUS_FS {
+		return mstatus | MSTATUS_SD
+	}
+	return mstatus &^ MSTATUS_SD
+}
+
+// legalizarMtvec mantém o modo anterior se o escrito for reservado (2 ou
+// 3); só os modos direto (0) e vetorizado (1) existem.
+func legalizarMtvec(_ *Machine, antigo, novo uint32) uint32 {
+	if novo&MTVEC_MODE > MTVEC_VETORIZADO {
+		return novo&^MTVEC_MODE | antigo&MTVEC_MODE
+	}
+	return novo
+}
+
+// valorMisa codifica em misa a base RV32 e as extensões da configuração.
+func valorMisa(cfg Config) uint32 {
+	extensao := func(letra byte) uint32 { return 1 << (letra - 'A') }
+	valor := uint32(1)<<30 | extensao('I') | extensao('M') | extensao('C') | extensao('S') | extensao('U')
+	if cfg.ExtA {
+		valor |= extensao('A')
+	}
+	if cfg.ExtF {
+		valor |= extensao('F')
+	}
+	return valor
+}
+
+// csrImplementado indica se o CSR existe na configuração da máquina.
+func (m *Machine) csrImplementado(endereco uint32) bool {
+	switch endereco {
+	case FFLAGS, FRM, FCSR:
+		return m.cfg.ExtF
+	}
+	if !m.cfg.Traps {
+		return false
+	}
+	if _, ok := registrosCSR[endereco]; ok {
+		return true
+	}
+	if _, ok := visoesCSR[endereco]; ok {
+		return true
+	}
+	_, ok := m.lerContador(endereco)
+	return ok
+}
+
+// csrAcessivel indica se o CSR pode ser acessado no nível de privilégio
+// atual: os bits 9:8 do endereço dão o nível mínimo e, abaixo do modo de
+// máquina, os apelidos de usuário dos contadores dependem de mcounteren e,
+// no modo de usuário, também de scounteren. Com mstatus.FS em Off, fflags,
+// frm e fcsr são inacessíveis.
+func (m *Machine) csrAcessivel(endereco uint32) bool {
+	if m.privilegio < (endereco>>8)&0x3 {
+		return false
+	}
+	switch endereco {
+	case FFLAGS, FRM, FCSR:
+		if m.fpuDesligada() {
+			return false
+		}
+	}
+	if indice, _, usuario, ok := ehContador(endereco); ok && usuario {
+		if m.privilegio < PRIV_M && m.CSR[MCOUNTEREN]&(1<<indice) == 0 {
+			return false
+		}
+		if m.privilegio < PRIV_S && m.CSR[SCOUNTEREN]&(1<<indice) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// csrSomenteLeitura indica se o endereço está numa faixa somente leitura.
+func csrSomenteLeitura(endereco uint32) bool {
+	return endereco>>10 == 0b11
+}
+
+// CSRAddr retorna o endereço do CSR pelo nome.
+func CSRAddr(nome string) (uint32, bool) {
+	endereco, ok := csrNames[nome]
+	return endereco, ok
+}
+
+// ReadCSR lê o CSR no endereço dado sem efeitos colaterais.
+func (m *Machine) ReadCSR(endereco uint32) uint32 {
+	return m.lerCSR(endereco)
+}
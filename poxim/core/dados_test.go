@@ -0,0 +1,175 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Testes diferenciais do caminho de dados: todo programa deve produzir na
+// v3, com qualquer geometria e política de escrita da cache de dados, os
+// mesmos registradores, memória e rastro de instruções da v2, que não tem
+// caches.
+
+const (
+	regBase      = 5  // t0: endereço dos dados
+	regValor     = 6  // t1: valor escrito
+	regLido      = 7  // t2: valor lido
+	regResultado = 28 // t3: ponteiro da área de resultados
+
+	enderecoDados      = RAMBase + 0x4000
+	enderecoResultados = RAMBase + 0x5000
+)
+
+// programa monta instruções RV32I em sequência.
+type programa []uint32
+
+func (p *programa) li(rd, valor uint32) {
+	alto := (valor + 0x800) >> 12
+	*p = append(*p, alto<<12|rd<<7|0x37)                 // lui
+	*p = append(*p, (valor&0xFFF)<<20|rd<<15|rd<<7|0x13) // addi
+}
+
+func (p *programa) addi(rd, rs1, imm uint32) {
+	*p = append(*p, (imm&0xFFF)<<20|rs1<<15|rd<<7|0x13)
+}
+
+func (p *programa) load(funct3, rd, rs1, imm uint32) {
+	*p = append(*p, (imm&0xFFF)<<20|rs1<<15|funct3<<12|rd<<7|0x03)
+}
+
+func (p *programa) store(funct3, rs2, rs1, imm uint32) {
+	*p = append(*p, (imm>>5&0x7F)<<25|rs2<<20|rs1<<15|funct3<<12|(imm&0x1F)<<7|0x23)
+}
+
+func (p *programa) ebreak() {
+	*p = append(*p, 0x00100073)
+}
+
+// programaSubpalavra preenche os dados com palavras conhecidas, escreve
+// tam bytes em deslocamento e lê de volta, com todas as larguras, os
+// endereços vizinhos, guardando cada leitura na área de resultados.
+func programaSubpalavra(funct3Store, deslocamento uint32) programa {
+	var p programa
+	p.li(regBase, enderecoDados)
+	p.li(regResultado, enderecoResultados)
+	for i := uint32(0); i < 6; i++ {
+		p.li(regValor, 0x80818283+i*0x04040404)
+		p.store(0b010, regValor, regBase, 4*i)
+	}
+	p.li(regValor, 0xA5B6C7D8)
+	p.store(funct3Store, regValor, regBase, deslocamento)
+
+	for _, funct3Load := range []uint32{0b000, 0b100, 0b001, 0b101, 0b010} {
+		for d := uint32(0); d < 20; d++ {
+			p.load(funct3Load, regLido, regBase, d)
+			p.store(0b010, regLido, regResultado, 0)
+			p.addi(regResultado, regResultado, 4)
+		}
+	}
+	p.ebreak()
+	return p
+}
+
+// executarPrograma roda p na configuração dada e retorna a máquina final e
+// o rastro sem as linhas das caches.
+func executarPrograma(t *testing.T, cfg Config, p programa) (*Machine, string) {
+	t.Helper()
+	var rastro bytes.Buffer
+	m := New(cfg, &rastro)
+	for i, instrucao := range p {
+		binary.LittleEndian.PutUint32(m.Mem[4*i:], instrucao)
+	}
+	for passos := 0; !m.Halted; passos++ {
+		if passos > 100000 {
+			t.Fatal("programa não terminou")
+		}
+		if err := m.Step(); err != nil {
+			t.Fatalf("erro na execução: %v", err)
+		}
+	}
+
+	var instrucoes strings.Builder
+	for _, linha := range strings.SplitAfter(rastro.String(), "\n") {
+		if !strings.HasPrefix(linha, "#cache_mem:") {
+			instrucoes.WriteString(linha)
+		}
+	}
+	return m, instrucoes.String()
+}
+
+// configuracoesCache são as variações da v3 comparadas com a v2: cada
+// geometria da dcache em escrita direta e em write-back, sem L2 e com L2
+// pequenas, inclusiva e não inclusiva, para forçar substituições.
+func configuracoesCache() map[string]Config {
+	configs := make(map[string]Config)
+	geometrias := map[string]CacheConfig{
+		"padrao":      {},
+		"direta":      {Size: 32, BlockSize: 8, Assoc: 1},
+		"associativa": {Size: 64, BlockSize: 4, Assoc: 0},
+	}
+	l2s := map[string]struct {
+		cfg       CacheConfig
+		inclusiva bool
+	}{
+		"":           {},
+		"/l2":        {CacheConfig{Size: 64, BlockSize: 32, Assoc: 1}, false},
+		"/l2-incl":   {CacheConfig{Size: 64, BlockSize: 32, Assoc: 1}, true},
+		"/l2-minima": {CacheConfig{Size: 32, BlockSize: 16, Assoc: 2}, true},
+	}
+	for nome, g := range geometrias {
+		for _, wb := range []bool{false, true} {
+			for nomeL2, l2 := range l2s {
+				cfg := ProfileV3()
+				cfg.DCache = g
+				cfg.DCache.WriteBack = wb
+				cfg.L2, cfg.L2Inclusiva = l2.cfg, l2.inclusiva
+				escrita := "wt"
+				if wb {
+					escrita = "wb"
+				}
+				configs[nome+"/"+escrita+nomeL2] = cfg
+			}
+		}
+	}
+	return configs
+}
+
+func TestDadosSubpalavraIgualV2(t *testing.T) {
+	lojas := map[uint32]string{0b000: "sb", 0b001: "sh", 0b010: "sw"}
+	for funct3, nomeStore := range lojas {
+		for deslocamento := uint32(0); deslocamento < 16; deslocamento++ {
+			p := programaSubpalavra(funct3, deslocamento)
+			esperado, rastroEsperado := executarPrograma(t, ProfileV2(), p)
+			for nome, cfg := range configuracoesCache() {
+				t.Run(fmt.Sprintf("%s+%d/%s", nomeStore, deslocamento, nome), func(t *testing.T) {
+					m, rastro := executarPrograma(t, cfg, p)
+					if rastro != rastroEsperado {
+						t.Errorf("rastro difere da v2:\n%s", primeiraDiferenca(rastro, rastroEsperado))
+					}
+					for i := range esperado.X {
+						if m.X[i] != esperado.X[i] {
+							t.Errorf("x%d = 0x%08x, v2 = 0x%08x", i, uint32(m.X[i]), uint32(esperado.X[i]))
+						}
+					}
+					if !bytes.Equal(m.Mem, esperado.Mem) {
+						t.Errorf("memória final difere da v2")
+					}
+				})
+			}
+		}
+	}
+}
+
+// primeiraDiferenca mostra a primeira linha em que os rastros divergem.
+func primeiraDiferenca(obtido, esperado string) string {
+	a, b := strings.Split(obtido, "\n"), strings.Split(esperado, "\n")
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return fmt.Sprintf("linha %d:\n  v3: %s\n  v2: %s", i+1, a[i], b[i])
+		}
+	}
+	return fmt.Sprintf("tamanhos diferentes: %d e %d linhas", len(a), len(b))
+}
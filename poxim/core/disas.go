@@ -0,0 +1,265 @@
+package core
+
+import (
+	"fmt"
+)
+
+// Disassemble decodifica a instrução em pc no mesmo formato das linhas do
+// rastro (mnemônico na coluna de 7 caracteres e operandos com os nomes
+// ABI). instrucao pode ser comprimida, caso em que apenas os 16 bits
+// inferiores são usados; tamanho informa quantos bytes ela ocupa. Se a
+// codificação for inválida, valido é false e texto descreve a palavra.
+func Disassemble(instrucao, pc uint32) (texto string, tamanho uint32, valido bool) {
+	tamanho = 4
+	ilegal := fmt.Sprintf("illegal 0x%08x", instrucao)
+	nomeC := ""
+	if instrucao&0x3 != 0x3 {
+		tamanho = 2
+		instrucao &= 0xFFFF
+		ilegal = fmt.Sprintf("illegal 0x%04x", instrucao)
+		expandida, nome, ok := expandirComprimida(instrucao)
+		if !ok {
+			return ilegal, tamanho, false
+		}
+		instrucao, nomeC = expandida, nome
+	}
+
+	inst, operandos, ok := desmontar(instrucao, pc)
+	if !ok {
+		return ilegal, tamanho, false
+	}
+	if nomeC != "" {
+		inst = nomeC
+	}
+	if operandos == "" {
+		return inst, tamanho, true
+	}
+	if len(inst) >= 7 {
+		inst += " "
+	}
+	return fmt.Sprintf("%-7s%s", inst, operandos), tamanho, true
+}
+
+// desmontar decodifica uma instrução de 32 bits em mnemônico e operandos.
+// Ao contrário de executar, rejeita todos os campos reservados.
+func desmontar(instrucao, pc uint32) (inst, operandos string, ok bool) {
+	opcode, rd, rs1, rs2, funct3, funct7 := campos(instrucao)
+	immSinalI := imediatoI(instrucao)
+
+	switch opcode {
+	case 0b0110111: // lui
+		return "lui", fmt.Sprintf("%s,0x%05x", xLabel[rd], instrucao>>12), true
+
+	case 0b0010111: // auipc
+		return "auipc", fmt.Sprintf("%s,0x%05x", xLabel[rd], instrucao>>12), true
+
+	case 0b0000011: // Loads
+		nomes := [8]string{"lb", "lh", "lw", "", "lbu", "lhu", "", ""}
+		if nomes[funct3] == "" {
+			return "", "", false
+		}
+		return nomes[funct3], fmt.Sprintf("%s,0x%03x(%s)", xLabel[rd], immSinalI&0xFFF, xLabel[rs1]), true
+
+	case 0b0100011: // Stores
+		nomes := [8]string{"sb", "sh", "sw", "", "", "", "", ""}
+		if nomes[funct3] == "" {
+			return "", "", false
+		}
+		immSinalS := imediatoS(instrucao)
+		return nomes[funct3], fmt.Sprintf("%s,0x%03x(%s)", xLabel[rs2], immSinalS&0xFFF, xLabel[rs1]), true
+
+	case 0b0110011: // R-type e M
+		var nomes [8]string
+		switch funct7 {
+		case 0b0000000:
+			nomes = [8]string{"add", "sll", "slt", "sltu", "xor", "srl", "or", "and"}
+		case 0b0100000:
+			nomes = [8]string{"sub", "", "", "", "", "sra", "", ""}
+		case 0b0000001:
+			nomes = [8]string{"mul", "mulh", "mulhsu", "mulhu", "div", "divu", "rem", "remu"}
+		}
+		if nomes[funct3] == "" {
+			return "", "", false
+		}
+		return nomes[funct3], fmt.Sprintf("%s,%s,%s", xLabel[rd], xLabel[rs1], xLabel[rs2]), true
+
+	case 0b0010011: // I-type
+		quantDeslocamento := rs2 // shamt ocupa o campo rs2
+		switch funct3 {
+		case 0b001:
+			if funct7 != 0 {
+				return "", "", false
+			}
+			return "slli", fmt.Sprintf("%s,%s,%d", xLabel[rd], xLabel[rs1], quantDeslocamento), true
+		case 0b101:
+			switch funct7 {
+			case 0b0000000:
+				inst = "srli"
+			case 0b0100000:
+				inst = "srai"
+			default:
+				return "", "", false
+			}
+			return inst, fmt.Sprintf("%s,%s,%d", xLabel[rd], xLabel[rs1], quantDeslocamento), true
+		}
+		nomes := [8]string{"addi", "", "slti", "sltiu", "xori", "", "ori", "andi"}
+		return nomes[funct3], fmt.Sprintf("%s,%s,0x%03x", xLabel[rd], xLabel[rs1], immSinalI&0xFFF), true
+
+	case 0b1100011: // Branches
+		nomes := [8]string{"beq", "bne", "", "", "blt", "bge", "bltu", "bgeu"}
+		if nomes[funct3] == "" {
+			return "", "", false
+		}
+		pcAlvo := pc + uint32(imediatoB(instrucao))
+		return nomes[funct3], fmt.Sprintf("%s,%s,0x%08x", xLabel[rs1], xLabel[rs2], pcAlvo), true
+
+	case 0b1101111: // jal
+		pcAlvo := pc + uint32(imediatoJ(instrucao))
+		return "jal", fmt.Sprintf("%s,0x%08x", xLabel[rd], pcAlvo), true
+
+	case 0b1100111: // jalr
+		if funct3 != 0 {
+			return "", "", false
+		}
+		return "jalr", fmt.Sprintf("%s,%s,0x%03x", xLabel[rd], xLabel[rs1], immSinalI&0xFFF), true
+
+	case 0b0001111: // fence
+		switch funct3 {
+		case 0b000:
+			return "fence", "", true
+		case 0b001:
+			return "fence.i", "", true
+		}
+		return "", "", false
+
+	case 0b0101111: // RV32A
+		funct5 := campoRs3(instrucao)
+		inst, existe := amoInst[funct5]
+		if !existe || funct3 != 0b010 || (funct5 == 0b00010 && rs2 != 0) {
+			return "", "", false
+		}
+		switch (instrucao >> 25) & 0x3 {
+		case 0b10:
+			inst += ".aq"
+		case 0b01:
+			inst += ".rl"
+		case 0b11:
+			inst += ".aqrl"
+		}
+		if funct5 == 0b00010 {
+			return inst, fmt.Sprintf("%s,(%s)", xLabel[rd], xLabel[rs1]), true
+		}
+		return inst, fmt.Sprintf("%s,%s,(%s)", xLabel[rd], xLabel[rs2], xLabel[rs1]), true
+
+	case 0b0000111, 0b0100111, 0b1000011, 0b1000111, 0b1001011, 0b1001111, 0b1010011: // RV32F
+		return desmontarFlutuante(instrucao)
+
+	case 0b1110011: // SYSTEM
+		csrAddr := instrucao >> 20
+		switch funct3 {
+		case 0b000:
+			if rd != 0 || rs1 != 0 {
+				return "", "", false
+			}
+			switch csrAddr {
+			case 0b000000000000:
+				return "ecall", "", true
+			case 0b000000000001:
+				return "ebreak", "", true
+			case 0b001100000010:
+				return "mret", "", true
+			case 0b000100000010:
+				return "sret", "", true
+			case 0b000100000101:
+				return "wfi", "", true
+			}
+			return "", "", false
+		case 0b001, 0b010, 0b011:
+			return csrInst[funct3], fmt.Sprintf("%s,0x%03x,%s", xLabel[rd], csrAddr, xLabel[rs1]), true
+		case 0b101, 0b110, 0b111:
+			return csrInst[funct3], fmt.Sprintf("%s,0x%03x,%d", xLabel[rd], csrAddr, rs1), true
+		}
+	}
+	return "", "", false
+}
+
+// desmontarFlutuante decodifica as instruções da extensão F.
+func desmontarFlutuante(instrucao uint32) (inst, operandos string, ok bool) {
+	opcode, rd, rs1, rs2, funct3, funct7 := campos(instrucao)
+	rs3 := campoRs3(instrucao)
+
+	switch opcode {
+	case 0b0000111: // flw
+		if funct3 != 0b010 {
+			return "", "", false
+		}
+		imm := imediatoI(instrucao)
+		return "flw", fmt.Sprintf("%s,0x%03x(%s)", fLabel[rd], imm&0xFFF, xLabel[rs1]), true
+	case 0b0100111: // fsw
+		if funct3 != 0b010 {
+			return "", "", false
+		}
+		imm := imediatoS(instrucao)
+		return "fsw", fmt.Sprintf("%s,0x%03x(%s)", fLabel[rs2], imm&0xFFF, xLabel[rs1]), true
+	}
+
+	if (instrucao>>25)&0x3 != 0 {
+		return "", "", false
+	}
+	// Sufixo do modo de arredondamento estático; 5 e 6 são reservados
+	sufixoRm := func() (string, bool) {
+		if funct3 == RM_DYN {
+			return "", true
+		}
+		return "," + rmLabel[funct3], funct3 <= RM_RMM
+	}
+
+	if opcode != 0b1010011 {
+		nomes := map[uint32]string{0b1000011: "fmadd.s", 0b1000111: "fmsub.s", 0b1001011: "fnmsub.s", 0b1001111: "fnmadd.s"}
+		rm, valido := sufixoRm()
+		if !valido {
+			return "", "", false
+		}
+		return nomes[opcode], fmt.Sprintf("%s,%s,%s,%s%s", fLabel[rd], fLabel[rs1], fLabel[rs2], fLabel[rs3], rm), true
+	}
+
+	fff := fmt.Sprintf("%s,%s,%s", fLabel[rd], fLabel[rs1], fLabel[rs2])
+	switch funct7 {
+	case 0b0000000, 0b0000100, 0b0001000, 0b0001100:
+		nomes := map[uint32]string{0b0000000: "fadd.s", 0b0000100: "fsub.s", 0b0001000: "fmul.s", 0b0001100: "fdiv.s"}
+		rm, valido := sufixoRm()
+		return nomes[funct7], fff + rm, valido
+	case 0b0101100:
+		rm, valido := sufixoRm()
+		return "fsqrt.s", fmt.Sprintf("%s,%s%s", fLabel[rd], fLabel[rs1], rm), valido && rs2 == 0
+	case 0b0010000:
+		nomes := [8]string{"fsgnj.s", "fsgnjn.s", "fsgnjx.s"}
+		return nomes[funct3], fff, nomes[funct3] != ""
+	case 0b0010100:
+		nomes := [8]string{"fmin.s", "fmax.s"}
+		return nomes[funct3], fff, nomes[funct3] != ""
+	case 0b1010000:
+		nomes := [8]string{"fle.s", "flt.s", "feq.s"}
+		return nomes[funct3], fmt.Sprintf("%s,%s,%s", xLabel[rd], fLabel[rs1], fLabel[rs2]), nomes[funct3] != ""
+	case 0b1100000:
+		rm, valido := sufixoRm()
+		nomes := [2]string{"fcvt.w.s", "fcvt.wu.s"}
+		if rs2 > 1 {
+			return "", "", false
+		}
+		return nomes[rs2], fmt.Sprintf("%s,%s%s", xLabel[rd], fLabel[rs1], rm), valido
+	case 0b1101000:
+		rm, valido := sufixoRm()
+		nomes := [2]string{"fcvt.s.w", "fcvt.s.wu"}
+		if rs2 > 1 {
+			return "", "", false
+		}
+		return nomes[rs2], fmt.Sprintf("%s,%s%s", fLabel[rd], xLabel[rs1], rm), valido
+	case 0b1110000:
+		nomes := [8]string{"fmv.x.w", "fclass.s"}
+		return nomes[funct3], fmt.Sprintf("%s,%s", xLabel[rd], fLabel[rs1]), rs2 == 0 && nomes[funct3] != ""
+	case 0b1111000:
+		return "fmv.w.x", fmt.Sprintf("%s,%s", fLabel[rd], xLabel[rs1]), rs2 == 0 && funct3 == 0
+	}
+	return "", "", false
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"io"
+)
+
+// ehELF indica se o início do arquivo tem a assinatura de um ELF.
+func ehELF(cabecalho []byte) bool {
+	return bytes.HasPrefix(cabecalho, []byte(elf.ELFMAG))
+}
+
+// carregarELF carrega os segmentos PT_LOAD de um executável ELF32 RISC-V
+// little-endian na RAM, zera o restante de cada segmento (.bss) e inicia
+// a execução em e_entry. A tabela de símbolos, se houver, é guardada para
+// as anotações do rastro.
+func (m *Machine) carregarELF(arquivo io.ReaderAt) error {
+	// Classe e codificação são verificadas antes do restante do cabeçalho,
+	// cujo formato depende delas
+	ident := make([]byte, elf.EI_NIDENT)
+	if _, err := arquivo.ReadAt(ident, 0); err != nil {
+		return fmt.Errorf("ELF inválido: %w", err)
+	}
+	if classe := elf.Class(ident[elf.EI_CLASS]); classe != elf.ELFCLASS32 {
+		return fmt.Errorf("ELF de classe %v não suportado, esperado ELFCLASS32", classe)
+	}
+	if dados := elf.Data(ident[elf.EI_DATA]); dados != elf.ELFDATA2LSB {
+		return fmt.Errorf("ELF com codificação %v não suportado, esperado little-endian", dados)
+	}
+
+	f, err := elf.NewFile(arquivo)
+	if err != nil {
+		return fmt.Errorf("ELF inválido: %w", err)
+	}
+	defer f.Close()
+
+	if f.Machine != elf.EM_RISCV {
+		return fmt.Errorf("ELF para a máquina %v não suportado, esperado EM_RISCV", f.Machine)
+	}
+	if f.Type != elf.ET_EXEC {
+		return fmt.Errorf("ELF do tipo %v não suportado, esperado ET_EXEC", f.Type)
+	}
+
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_LOAD || prog.Memsz == 0 {
+			continue
+		}
+		endereco := uint32(prog.Paddr)
+		if prog.Filesz > prog.Memsz || uint64(endereco) != prog.Paddr || !m.naRAM(endereco, uint32(prog.Memsz)) {
+			return fmt.Errorf("segmento PT_LOAD em 0x%08x (0x%x bytes) fora da RAM", prog.Paddr, prog.Memsz)
+		}
+		destino := m.Mem[endereco-RAMBase : endereco-RAMBase+uint32(prog.Memsz)]
+		if _, err := io.ReadFull(prog.Open(), destino[:prog.Filesz]); err != nil {
+			return fmt.Errorf("falha ao ler segmento em 0x%08x: %w", prog.Paddr, err)
+		}
+		clear(destino[prog.Filesz:]) // .bss
+		m.segmentos = append(m.segmentos, Segment{Addr: endereco, Size: uint32(prog.Filesz), Exec: prog.Flags&elf.PF_X != 0})
+	}
+
+	m.simbolos = lerSimbolos(f)
+	m.PC = uint32(f.Entry)
+	return nil
+}
@@ -0,0 +1,566 @@
+package core
+
+import (
+	"fmt"
+)
+
+var xLabel = []string{
+	"zero", "ra", "sp", "gp", "tp", "t0", "t1", "t2", "s0", "s1",
+	"a0", "a1", "a2", "a3", "a4", "a5", "a6", "a7", "s2", "s3",
+	"s4", "s5", "s6", "s7", "s8", "s9", "s10", "s11", "t3", "t4",
+	"t5", "t6",
+}
+
+// XLabel retorna o nome ABI do registrador inteiro i.
+func XLabel(i int) string {
+	return xLabel[i]
+}
+
+// Nomes das instruções de CSR indexados por funct3
+var csrInst = [8]string{"", "csrrw", "csrrs", "csrrc", "", "csrrwi", "csrrsi", "csrrci"}
+
+// sufixoResultado retorna o complemento " -> 0x..." das linhas dos tipos R
+// e I, presente a partir da v2.
+func (m *Machine) sufixoResultado(data int32) string {
+	if !m.cfg.RastroResultado {
+		return ""
+	}
+	return fmt.Sprintf(" -> 0x%08x", uint32(data))
+}
+
+// nomeInst retorna o mnemônico a exibir no rastro: o da instrução
+// comprimida original, se houver, ou inst. Nomes que ocupam toda a coluna
+// de 7 caracteres recebem um espaço para não colar nos operandos.
+func (m *Machine) nomeInst(inst string) string {
+	if m.nomeC != "" {
+		inst = m.nomeC
+	}
+	if len(inst) >= 7 {
+		return inst + " "
+	}
+	return inst
+}
+
+// executar decodifica e executa uma instrução de 32 bits no PC atual.
+func (m *Machine) executar(instrucao uint32) {
+	x := m.X
+	pc := m.PC
+	writer := m.trace
+
+	opcode, rd, rs1, rs2, funct3, funct7 := campos(instrucao)
+
+	switch opcode {
+	case 0b0110111: // lui
+		immU := instrucao & 0xFFFFF000
+		resultado := int32(immU)
+		fmt.Fprintf(writer, "%s:%-7s%s,0x%05x   rd=0x%08x\n", m.rotuloPC(pc), m.nomeInst("lui"), xLabel[rd], immU>>12, uint32(resultado))
+		if rd != 0 {
+			x[rd] = resultado
+		}
+
+	case 0b0010111: // auipc
+		immU := instrucao & 0xFFFFF000
+		resultado := int32(pc) + int32(immU)
+		fmt.Fprintf(writer, "%s:%-7s%s,0x%05x   rd=0x%08x+0x%08x=0x%08x\n", m.rotuloPC(pc), m.nomeInst("auipc"), xLabel[rd], immU>>12, pc, immU, uint32(resultado))
+		if rd != 0 {
+			x[rd] = resultado
+		}
+
+	case 0b0000011: // Load instructions
+		immSinalI := imediatoI(instrucao)
+		enderecoMem := uint32(x[rs1]) + uint32(immSinalI)
+
+		var data int32
+		inst := ""
+		tam := uint32(1) << (funct3 & 0x3)
+		switch funct3 {
+		case 0b000: // lb
+			inst = "lb"
+		case 0b100: // lbu
+			inst = "lbu"
+		case 0b001: // lh
+			inst = "lh"
+		case 0b101: // lhu
+			inst = "lhu"
+		case 0b010: // lw
+			inst = "lw"
+		default:
+			m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+			return
+		}
+
+		valor, ok := m.lerDados(enderecoMem, tam)
+		if !ok {
+			m.excecao(EXC_LOAD_ACCESS_FAULT, enderecoMem)
+			return
+		}
+		switch funct3 {
+		case 0b000: // lb
+			data = int32(int8(valor))
+		case 0b001: // lh
+			data = int32(int16(valor))
+		default: // lbu, lhu, lw
+			data = int32(valor)
+		}
+
+		fmt.Fprintf(writer, "%s:%-7s%s,0x%03x(%s)   %s=mem[0x%08x]=0x%08x\n", m.rotuloPC(pc), m.nomeInst(inst), xLabel[rd], immSinalI&0xFFF, xLabel[rs1], xLabel[rd], enderecoMem, uint32(data))
+		if rd != 0 {
+			x[rd] = data
+		}
+
+	case 0b0100011: // Store instructions
+		immSinalS := imediatoS(instrucao)
+		enderecoMem := uint32(x[rs1]) + uint32(immSinalS)
+
+		inst := ""
+		stringOperacao := ""
+		var val uint32
+		switch funct3 {
+		case 0b000: // sb
+			inst = "sb"
+			val = uint32(byte(x[rs2]))
+			stringOperacao = fmt.Sprintf("0x%02x", val)
+		case 0b001: // sh
+			inst = "sh"
+			val = uint32(uint16(x[rs2]))
+			stringOperacao = fmt.Sprintf("0x%04x", val)
+		case 0b010: // sw
+			inst = "sw"
+			val = uint32(x[rs2])
+			stringOperacao = fmt.Sprintf("0x%08x", val)
+		default:
+			m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+			return
+		}
+
+		if !m.escreverDados(enderecoMem, uint32(1)<<funct3, val) {
+			m.excecao(EXC_STORE_ACCESS_FAULT, enderecoMem)
+			return
+		}
+		fmt.Fprintf(writer, "%s:%-7s%s,0x%03x(%s)   mem[0x%08x]=%s\n", m.rotuloPC(pc), m.nomeInst(inst), xLabel[rs2], immSinalS&0xFFF, xLabel[rs1], enderecoMem, stringOperacao)
+
+	case 0b0110011: // R-type
+		var data int32
+		inst := ""
+		stringOperacao := ""
+		quantDeslocamento := uint32(x[rs2]) & 0x1F
+
+		if funct7 == 0b0000001 {
+			s1, s2 := x[rs1], x[rs2]
+			u1, u2 := uint32(s1), uint32(s2)
+			switch funct3 {
+			case 0b000: // mul
+				inst, stringOperacao = "mul", fmt.Sprintf("0x%08x*0x%08x", u1, u2)
+				data = s1 * s2
+			case 0b001: // mulh
+				inst, stringOperacao = "mulh", fmt.Sprintf("(hi)0x%08x*0x%08x", u1, u2)
+				data = int32((int64(s1) * int64(s2)) >> 32)
+			case 0b010: // mulhsu
+				inst, stringOperacao = "mulhsu", fmt.Sprintf("(hi)0x%08x*(U)0x%08x", u1, u2)
+				data = int32((int64(s1) * int64(int64(u2)&0xFFFFFFFF)) >> 32)
+			case 0b011: // mulhu
+				inst, stringOperacao = "mulhu", fmt.Sprintf("(hi)(U)0x%08x*(U)0x%08x", u1, u2)
+				data = int32((uint64(u1) * uint64(u2)) >> 32)
+			case 0b100: // div
+				inst, stringOperacao = "div", fmt.Sprintf("0x%08x/0x%08x", u1, u2)
+				if s2 == 0 {
+					data = -1
+				} else if s1 == -2147483648 && s2 == -1 {
+					data = s1
+				} else {
+					data = s1 / s2
+				}
+			case 0b101: // divu
+				inst, stringOperacao = "divu", fmt.Sprintf("(U)0x%08x/(U)0x%08x", u1, u2)
+				if u2 == 0 {
+					data = -1
+				} else {
+					data = int32(u1 / u2)
+				}
+			case 0b110: // rem
+				inst, stringOperacao = "rem", fmt.Sprintf("0x%08x%%0x%08x", u1, u2)
+				if s2 == 0 {
+					data = s1
+				} else if s1 == -2147483648 && s2 == -1 {
+					data = 0
+				} else {
+					data = s1 % s2
+				}
+			case 0b111: // remu
+				inst, stringOperacao = "remu", fmt.Sprintf("(U)0x%08x%%(U)0x%08x", u1, u2)
+				if u2 == 0 {
+					data = int32(u1)
+				} else {
+					data = int32(u1 % u2)
+				}
+			}
+		} else {
+			switch funct3 {
+			case 0b111: // and
+				inst, stringOperacao = "and", fmt.Sprintf("0x%08x&0x%08x", uint32(x[rs1]), uint32(x[rs2]))
+				data = x[rs1] & x[rs2]
+			case 0b110: // or
+				inst, stringOperacao = "or", fmt.Sprintf("0x%08x|0x%08x", uint32(x[rs1]), uint32(x[rs2]))
+				data = x[rs1] | x[rs2]
+			case 0b100: // xor
+				inst, stringOperacao = "xor", fmt.Sprintf("0x%08x^0x%08x", uint32(x[rs1]), uint32(x[rs2]))
+				data = x[rs1] ^ x[rs2]
+			case 0b001: // sll
+				inst, stringOperacao = "sll", fmt.Sprintf("0x%08x<<%d", uint32(x[rs1]), quantDeslocamento)
+				data = x[rs1] << quantDeslocamento
+			case 0b101:
+				if funct7 == 0 { // srl
+					inst, stringOperacao = "srl", fmt.Sprintf("0x%08x>>%d", uint32(x[rs1]), quantDeslocamento)
+					data = int32(uint32(x[rs1]) >> quantDeslocamento)
+				} else { // sra
+					inst, stringOperacao = "sra", fmt.Sprintf("0x%08x>>%d", uint32(x[rs1]), quantDeslocamento)
+					data = x[rs1] >> quantDeslocamento
+				}
+			case 0b010: // slt
+				inst, stringOperacao = "slt", fmt.Sprintf("(0x%08x<0x%08x)", uint32(x[rs1]), uint32(x[rs2]))
+				if x[rs1] < x[rs2] {
+					data = 1
+				} else {
+					data = 0
+				}
+			case 0b011: // sltu
+				inst, stringOperacao = "sltu", fmt.Sprintf("(0x%08x<0x%08x) (unsigned)", uint32(x[rs1]), uint32(x[rs2]))
+				if uint32(x[rs1]) < uint32(x[rs2]) {
+					data = 1
+				} else {
+					data = 0
+				}
+			case 0b000:
+				if funct7 == 0 { // add
+					inst, stringOperacao = "add", fmt.Sprintf("0x%08x+0x%08x", uint32(x[rs1]), uint32(x[rs2]))
+					data = x[rs1] + x[rs2]
+				} else { // sub
+					inst, stringOperacao = "sub", fmt.Sprintf("0x%08x-0x%08x", uint32(x[rs1]), uint32(x[rs2]))
+					data = x[rs1] - x[rs2]
+				}
+			}
+		}
+		fmt.Fprintf(writer, "%s:%-7s%s,%s,%s   %s%s\n", m.rotuloPC(pc), m.nomeInst(inst), xLabel[rd], xLabel[rs1], xLabel[rs2], stringOperacao, m.sufixoResultado(data))
+		if rd != 0 {
+			x[rd] = data
+		}
+
+	case 0b0010011: // I-type
+		immSinalI := imediatoI(instrucao)
+		quantDeslocamento := rs2 // shamt ocupa o campo rs2
+
+		var data int32
+		inst := ""
+		stringOperacao := ""
+
+		switch funct3 {
+		case 0b111: // andi
+			inst, stringOperacao = "andi", fmt.Sprintf("0x%08x&0x%08x", uint32(x[rs1]), uint32(immSinalI))
+			data = x[rs1] & immSinalI
+		case 0b110: // ori
+			inst, stringOperacao = "ori", fmt.Sprintf("0x%08x|0x%08x", uint32(x[rs1]), uint32(immSinalI))
+			data = x[rs1] | immSinalI
+		case 0b100: // xori
+			inst, stringOperacao = "xori", fmt.Sprintf("0x%08x^0x%08x", uint32(x[rs1]), uint32(immSinalI))
+			data = x[rs1] ^ immSinalI
+		case 0b001: // slli
+			inst, stringOperacao = "slli", fmt.Sprintf("0x%08x<<%d", uint32(x[rs1]), quantDeslocamento)
+			data = x[rs1] << quantDeslocamento
+		case 0b101:
+			if (instrucao >> 30) == 0 { // srli
+				inst, stringOperacao = "srli", fmt.Sprintf("0x%08x>>%d", uint32(x[rs1]), quantDeslocamento)
+				data = int32(uint32(x[rs1]) >> quantDeslocamento)
+			} else { // srai
+				inst, stringOperacao = "srai", fmt.Sprintf("0x%08x>>%d", uint32(x[rs1]), quantDeslocamento)
+				data = x[rs1] >> quantDeslocamento
+			}
+		case 0b010: // slti
+			inst, stringOperacao = "slti", fmt.Sprintf("(0x%08x<%d)", uint32(x[rs1]), immSinalI)
+			if x[rs1] < immSinalI {
+				data = 1
+			} else {
+				data = 0
+			}
+		case 0b011: // sltiu
+			inst, stringOperacao = "sltiu", fmt.Sprintf("(0x%08x<%d)", uint32(x[rs1]), immSinalI)
+			if uint32(x[rs1]) < uint32(immSinalI) {
+				data = 1
+			} else {
+				data = 0
+			}
+		case 0b000: // addi
+			inst, stringOperacao = "addi", fmt.Sprintf("0x%08x+0x%08x", uint32(x[rs1]), uint32(immSinalI))
+			data = x[rs1] + immSinalI
+		}
+
+		imediatoStr := fmt.Sprintf("0x%03x", immSinalI&0xFFF)
+		if funct3 == 0b001 || funct3 == 0b101 {
+			imediatoStr = fmt.Sprintf("%d", quantDeslocamento)
+		}
+		fmt.Fprintf(writer, "%s:%-7s%s,%s,%s   %s%s\n", m.rotuloPC(pc), m.nomeInst(inst), xLabel[rd], xLabel[rs1], imediatoStr, stringOperacao, m.sufixoResultado(data))
+		if rd != 0 {
+			x[rd] = data
+		}
+
+	case 0b1100011: // B-type
+		immSinalB := imediatoB(instrucao)
+
+		desviar := false
+		charOperacao := ""
+		inst := ""
+
+		switch funct3 {
+		case 0b000: // beq
+			inst, charOperacao = "beq", "=="
+			if x[rs1] == x[rs2] {
+				desviar = true
+			}
+		case 0b001: // bne
+			inst, charOperacao = "bne", "!="
+			if x[rs1] != x[rs2] {
+				desviar = true
+			}
+		case 0b100: // blt
+			inst, charOperacao = "blt", "<"
+			if x[rs1] < x[rs2] {
+				desviar = true
+			}
+		case 0b101: // bge
+			inst, charOperacao = "bge", ">="
+			if x[rs1] >= x[rs2] {
+				desviar = true
+			}
+		case 0b110: // bltu
+			inst, charOperacao = "bltu", "<(U)"
+			if uint32(x[rs1]) < uint32(x[rs2]) {
+				desviar = true
+			}
+		case 0b111: // bgeu
+			inst, charOperacao = "bgeu", ">=(U)"
+			if uint32(x[rs1]) >= uint32(x[rs2]) {
+				desviar = true
+			}
+		default:
+			m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+			return
+		}
+
+		resultadoComparacao := 0
+		m.contarEvento(HPM_EVENT_BRANCH)
+		if desviar {
+			resultadoComparacao = 1
+			m.contarEvento(HPM_EVENT_TAKEN_BRANCH)
+		}
+
+		pcAlvo := pc + uint32(immSinalB)
+		pcDestino := m.proximoPC
+		if desviar {
+			pcDestino = pcAlvo
+		}
+
+		fmt.Fprintf(writer, "%s:%-7s%s,%s,0x%08x   (0x%08x%s0x%08x)=%d->pc=0x%08x%s%s\n", m.rotuloPC(pc), m.nomeInst(inst), xLabel[rs1], xLabel[rs2], pcAlvo, uint32(x[rs1]), charOperacao, uint32(x[rs2]), resultadoComparacao, pcDestino, m.anotar(pcAlvo), m.preverDesvio(pc, pcAlvo, desviar))
+
+		m.proximoPC = pcDestino
+
+	case 0b1101111: // jal
+		immSinalJ := imediatoJ(instrucao)
+
+		valorRd := int32(m.proximoPC)
+		pcAlvo := pc + uint32(immSinalJ)
+		fmt.Fprintf(writer, "%s:%-7s%s,0x%08x   pc=0x%08x,rd=0x%08x%s%s\n", m.rotuloPC(pc), m.nomeInst("jal"), xLabel[rd], pcAlvo, pcAlvo, uint32(valorRd), m.anotar(pcAlvo), m.preverSalto(pc, pcAlvo))
+		if rd != 0 {
+			x[rd] = valorRd
+		}
+		m.proximoPC = pcAlvo
+
+	case 0b1100111: // jalr
+		immSinalI := imediatoI(instrucao)
+
+		valorRd := int32(m.proximoPC)
+		enderecoAlvo := (uint32(x[rs1]) + uint32(immSinalI)) & ^uint32(1)
+		fmt.Fprintf(writer, "%s:%-7s%s,%s,0x%03x   pc=0x%08x+0x%08x,rd=0x%08x%s%s\n", m.rotuloPC(pc), m.nomeInst("jalr"), xLabel[rd], xLabel[rs1], immSinalI&0xFFF, uint32(x[rs1]), uint32(immSinalI), uint32(valorRd), m.anotar(enderecoAlvo), m.preverSalto(pc, enderecoAlvo))
+		if rd != 0 {
+			x[rd] = valorRd
+		}
+		m.proximoPC = enderecoAlvo
+
+	case 0b0101111: // RV32A
+		if !m.cfg.ExtA {
+			m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+			return
+		}
+		m.executarAtomica(instrucao)
+
+	case 0b0000111, 0b0100111, 0b1000011, 0b1000111, 0b1001011, 0b1001111, 0b1010011: // RV32F
+		if !m.cfg.ExtF || m.fpuDesligada() {
+			m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+			return
+		}
+		m.executarFlutuante(instrucao)
+
+	case 0b0001111: // MISC-MEM
+		switch funct3 {
+		case 0b000: // fence: com um único hart, os acessos já são vistos em ordem
+			fmt.Fprintf(writer, "%s:fence\n", m.rotuloPC(pc))
+		case 0b001: // fence.i
+			m.sincronizarInstrucoes()
+			fmt.Fprintf(writer, "%s:fence.i\n", m.rotuloPC(pc))
+		default:
+			m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+		}
+
+	case 0b1110011: // SYSTEM
+		if funct3 == 0 && (instrucao>>20)&0xFFF == 0b000000000001 { // ebreak
+			inst := "ebreak"
+			if m.nomeC != "" {
+				inst = m.nomeC
+			}
+			fmt.Fprintf(writer, "%s:%s\n", m.rotuloPC(pc), inst)
+			m.Halted = true
+			if m.DCache != nil && m.DCache.writeBack {
+				m.flushCache(m.DCache) // Memória final consistente
+			}
+			return
+		}
+		if !m.cfg.Traps {
+			// Sem CSRs nem traps (v1): apenas ebreak é reconhecida
+			m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+			return
+		}
+		m.executarSystem(instrucao)
+
+	default:
+		m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+	}
+}
+
+// lerCSR lê um CSR, resolvendo fflags e frm como campos de fcsr.
+func (m *Machine) lerCSR(endereco uint32) uint32 {
+	switch endereco {
+	case FFLAGS:
+		return m.CSR[FCSR] & 0x1F
+	case FRM:
+		return (m.CSR[FCSR] >> 5) & 0x7
+	case FCSR:
+		return m.CSR[FCSR] & 0xFF
+	}
+	if valor, ok := m.lerContador(endereco); ok {
+		return valor
+	}
+	if v, ok := visoesCSR[endereco]; ok {
+		leitura, _ := v.mascara(m)
+		return m.CSR[v.base] & leitura
+	}
+	return m.CSR[endereco]
+}
+
+// escreverCSR escreve um CSR, resolvendo fflags e frm como campos de fcsr
+// e respeitando a máscara e os campos WARL do registro.
+func (m *Machine) escreverCSR(endereco, valor uint32) {
+	if m.escreverContador(endereco, valor) {
+		return
+	}
+	if v, ok := visoesCSR[endereco]; ok {
+		_, escrita := v.mascara(m)
+		m.escreverCSR(v.base, m.CSR[v.base]&^escrita|valor&escrita)
+		return
+	}
+	switch endereco {
+	case FFLAGS:
+		m.CSR[FCSR] = m.CSR[FCSR]&^0x1F | valor&0x1F
+		m.sujarFPU()
+	case FRM:
+		m.CSR[FCSR] = m.CSR[FCSR]&^0xE0 | (valor&0x7)<<5
+		m.sujarFPU()
+	case FCSR:
+		m.CSR[FCSR] = valor & 0xFF
+		m.sujarFPU()
+	default:
+		r := registrosCSR[endereco]
+		antigo := m.CSR[endereco]
+		novo := antigo&^r.mascara | valor&r.mascara
+		if r.legalizar != nil {
+			novo = r.legalizar(m, antigo, novo)
+		}
+		m.CSR[endereco] = novo
+	}
+}
+
+// executarSystem trata ecall, mret, sret, wfi e as instruções de CSR.
+func (m *Machine) executarSystem(instrucao uint32) {
+	x := m.X
+	pc := m.PC
+	writer := m.trace
+
+	_, rd, rs1, _, funct3, _ := campos(instrucao)
+	csrAddr := (instrucao >> 20) & 0xFFF
+	immU := (instrucao >> 15) & 0x1F
+
+	switch funct3 {
+	case 0b000:
+		switch csrAddr {
+		case 0b000000000000: // ecall: a causa depende do nível de privilégio
+			m.excecao(EXC_ECALL_FROM_U_MODE+m.privilegio, 0)
+		case 0b001100000010: // mret
+			if m.privilegio < PRIV_M {
+				m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+				return
+			}
+			fmt.Fprintf(writer, "%s:mret\n", m.rotuloPC(pc))
+			m.retornarDeTrap(trapMaquina)
+		case 0b000100000010: // sret
+			if m.privilegio < PRIV_S {
+				m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+				return
+			}
+			fmt.Fprintf(writer, "%s:sret\n", m.rotuloPC(pc))
+			m.retornarDeTrap(trapSupervisor)
+		case 0b000100000101: // wfi: retorna de imediato, o que a especificação permite
+			fmt.Fprintf(writer, "%s:wfi\n", m.rotuloPC(pc))
+		default:
+			m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+		}
+
+	case 0b001, 0b010, 0b011, 0b101, 0b110, 0b111: // csrrw, csrrs, csrrc e formas imediatas
+		operando := uint32(x[rs1])
+		if funct3&0b100 != 0 {
+			operando = immU
+		}
+
+		// csrrw não lê o CSR se rd = x0; csrrs e csrrc não o escrevem se
+		// rs1 = x0 (ou uimm = 0)
+		csrrw := funct3&0b011 == 0b01
+		escreve := csrrw || rs1 != 0
+		if !m.csrImplementado(csrAddr) || !m.csrAcessivel(csrAddr) || escreve && csrSomenteLeitura(csrAddr) {
+			m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+			return
+		}
+
+		var valorTemp uint32
+		if !csrrw || rd != 0 {
+			valorTemp = m.lerCSR(csrAddr)
+		}
+		if escreve {
+			switch funct3 & 0b011 {
+			case 0b01: // csrrw
+				m.escreverCSR(csrAddr, operando)
+			case 0b10: // csrrs
+				m.escreverCSR(csrAddr, valorTemp|operando)
+			case 0b11: // csrrc
+				m.escreverCSR(csrAddr, valorTemp&^operando)
+			}
+		}
+		if rd != 0 {
+			x[rd] = int32(valorTemp)
+		}
+
+		if funct3&0b100 != 0 {
+			fmt.Fprintf(writer, "%s:%-7s%s,0x%03x,%d\n", m.rotuloPC(pc), csrInst[funct3], xLabel[rd], csrAddr, immU)
+		} else {
+			fmt.Fprintf(writer, "%s:%-7s%s,0x%03x,%s\n", m.rotuloPC(pc), csrInst[funct3], xLabel[rd], csrAddr, xLabel[rs1])
+		}
+
+	default:
+		m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+	}
+}
@@ -0,0 +1,307 @@
+package core
+
+import (
+	"fmt"
+)
+
+var fLabel = []string{
+	"ft0", "ft1", "ft2", "ft3", "ft4", "ft5", "ft6", "ft7", "fs0", "fs1",
+	"fa0", "fa1", "fa2", "fa3", "fa4", "fa5", "fa6", "fa7", "fs2", "fs3",
+	"fs4", "fs5", "fs6", "fs7", "fs8", "fs9", "fs10", "fs11", "ft8", "ft9",
+	"ft10", "ft11",
+}
+
+// FLabel retorna o nome ABI do registrador de ponto flutuante i.
+func FLabel(i int) string {
+	return fLabel[i]
+}
+
+// Nomes dos modos de arredondamento estáticos, exibidos no rastro
+var rmLabel = [8]string{"rne", "rtz", "rdn", "rup", "rmm", "", "", ""}
+
+// Os registradores f têm 64 bits para seguir a regra de NaN-boxing: um
+// valor de precisão simples é guardado com os 32 bits superiores em 1, e
+// qualquer valor mal encaixotado é lido como o NaN canônico.
+const caixaNaN uint64 = 0xFFFFFFFF00000000
+
+// lerF retorna o valor de precisão simples do registrador f[r].
+func (m *Machine) lerF(r uint32) uint32 {
+	v := m.F[r]
+	if v&caixaNaN != caixaNaN {
+		return nanCanonico
+	}
+	return uint32(v)
+}
+
+// escreverF grava um valor de precisão simples em f[r], encaixotado.
+func (m *Machine) escreverF(r uint32, valor uint32) {
+	m.F[r] = caixaNaN | uint64(valor)
+	m.sujarFPU()
+}
+
+// fpuDesligada indica se mstatus.FS está em Off, caso em que as instruções
+// da extensão F e os acessos a fflags, frm e fcsr são ilegais.
+func (m *Machine) fpuDesligada() bool {
+	return m.cfg.Traps && m.CSR[MSTATUS]&MSTATUS_FS == 0
+}
+
+// sujarFPU marca em mstatus.FS que o estado da FPU (registradores f e
+// fcsr) foi alterado. É chamada depois de cada escrita nesse estado.
+func (m *Machine) sujarFPU() {
+	if m.cfg.Traps {
+		m.CSR[MSTATUS] |= MSTATUS_FS | MSTATUS_SD
+	}
+}
+
+// acumularFlags acrescenta as exceções de ponto flutuante a fflags.
+func (m *Machine) acumularFlags(flags uint32) {
+	if flags&0x1F != 0 {
+		m.CSR[FCSR] |= flags & 0x1F
+		m.sujarFPU()
+	}
+}
+
+// modoArredondamento resolve o campo rm da instrução, consultando frm
+// quando dinâmico. Retorna false para modos reservados.
+func (m *Machine) modoArredondamento(rm uint32) (uint32, bool) {
+	if rm == RM_DYN {
+		rm = (m.CSR[FCSR] >> 5) & 0x7
+	}
+	return rm, rm <= RM_RMM
+}
+
+// executarFlutuante executa flw, fsw, as instruções fused multiply-add e
+// as do grupo OP-FP da extensão F.
+func (m *Machine) executarFlutuante(instrucao uint32) {
+	x := m.X
+	pc := m.PC
+	writer := m.trace
+
+	opcode, rd, rs1, rs2, funct3, funct7 := campos(instrucao)
+	rs3 := campoRs3(instrucao)
+
+	switch opcode {
+	case 0b0000111: // flw
+		if funct3 != 0b010 {
+			m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+			return
+		}
+		immSinalI := imediatoI(instrucao)
+		enderecoMem := uint32(x[rs1]) + uint32(immSinalI)
+		data, ok := m.lerDados(enderecoMem, 4)
+		if !ok {
+			m.excecao(EXC_LOAD_ACCESS_FAULT, enderecoMem)
+			return
+		}
+		fmt.Fprintf(writer, "%s:%-7s%s,0x%03x(%s)   %s=mem[0x%08x]=0x%08x\n", m.rotuloPC(pc), m.nomeInst("flw"), fLabel[rd], immSinalI&0xFFF, xLabel[rs1], fLabel[rd], enderecoMem, data)
+		m.escreverF(rd, data)
+		return
+
+	case 0b0100111: // fsw
+		if funct3 != 0b010 {
+			m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+			return
+		}
+		immSinalS := imediatoS(instrucao)
+		enderecoMem := uint32(x[rs1]) + uint32(immSinalS)
+		// fsw grava os 32 bits inferiores sem verificar o encaixotamento
+		val := uint32(m.F[rs2])
+		if !m.escreverDados(enderecoMem, 4, val) {
+			m.excecao(EXC_STORE_ACCESS_FAULT, enderecoMem)
+			return
+		}
+		fmt.Fprintf(writer, "%s:%-7s%s,0x%03x(%s)   mem[0x%08x]=0x%08x\n", m.rotuloPC(pc), m.nomeInst("fsw"), fLabel[rs2], immSinalS&0xFFF, xLabel[rs1], enderecoMem, val)
+		return
+	}
+
+	// Demais instruções: apenas o formato S (fmt = 00) é suportado
+	if (instrucao>>25)&0x3 != 0 {
+		m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+		return
+	}
+
+	a, b := m.lerF(rs1), m.lerF(rs2)
+	var resultado, flags uint32
+	inst := ""
+
+	// Instruções que dependem do modo de arredondamento
+	arredonda := opcode != 0b1010011
+	switch funct7 {
+	case 0b0000000, 0b0000100, 0b0001000, 0b0001100, 0b0101100, 0b1100000, 0b1101000:
+		arredonda = true
+	}
+	rm := funct3
+	if arredonda {
+		var valido bool
+		rm, valido = m.modoArredondamento(funct3)
+		if !valido {
+			m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+			return
+		}
+	}
+	sufixoRm := ""
+	if arredonda && funct3 != RM_DYN {
+		sufixoRm = "," + rmLabel[funct3]
+	}
+
+	if opcode != 0b1010011 {
+		// Fused multiply-add
+		c := m.lerF(rs3)
+		var stringOperacao string
+		switch opcode {
+		case 0b1000011: // fmadd.s
+			inst, stringOperacao = "fmadd.s", fmt.Sprintf("(0x%08x*0x%08x)+0x%08x", a, b, c)
+			resultado, flags = fMulAdd(a, b, c, false, false, rm)
+		case 0b1000111: // fmsub.s
+			inst, stringOperacao = "fmsub.s", fmt.Sprintf("(0x%08x*0x%08x)-0x%08x", a, b, c)
+			resultado, flags = fMulAdd(a, b, c, false, true, rm)
+		case 0b1001011: // fnmsub.s
+			inst, stringOperacao = "fnmsub.s", fmt.Sprintf("-(0x%08x*0x%08x)+0x%08x", a, b, c)
+			resultado, flags = fMulAdd(a, b, c, true, false, rm)
+		case 0b1001111: // fnmadd.s
+			inst, stringOperacao = "fnmadd.s", fmt.Sprintf("-(0x%08x*0x%08x)-0x%08x", a, b, c)
+			resultado, flags = fMulAdd(a, b, c, true, true, rm)
+		}
+		fmt.Fprintf(writer, "%s:%-7s%s,%s,%s,%s%s   %s -> 0x%08x\n", m.rotuloPC(pc), m.nomeInst(inst), fLabel[rd], fLabel[rs1], fLabel[rs2], fLabel[rs3], sufixoRm, stringOperacao, resultado)
+		m.acumularFlags(flags)
+		m.escreverF(rd, resultado)
+		return
+	}
+
+	// OP-FP: destino em f, exceto comparações, conversões para inteiro,
+	// fmv.x.w e fclass, que escrevem em x
+	destinoX := false
+	operandos := fmt.Sprintf("%s,%s,%s", fLabel[rd], fLabel[rs1], fLabel[rs2])
+	stringOperacao := ""
+
+	switch funct7 {
+	case 0b0000000: // fadd.s
+		inst, stringOperacao = "fadd.s", fmt.Sprintf("0x%08x+0x%08x", a, b)
+		resultado, flags = fAdd(a, b, rm)
+	case 0b0000100: // fsub.s
+		inst, stringOperacao = "fsub.s", fmt.Sprintf("0x%08x-0x%08x", a, b)
+		resultado, flags = fAdd(a, b^sinalF32, rm)
+	case 0b0001000: // fmul.s
+		inst, stringOperacao = "fmul.s", fmt.Sprintf("0x%08x*0x%08x", a, b)
+		resultado, flags = fMul(a, b, rm)
+	case 0b0001100: // fdiv.s
+		inst, stringOperacao = "fdiv.s", fmt.Sprintf("0x%08x/0x%08x", a, b)
+		resultado, flags = fDiv(a, b, rm)
+	case 0b0101100: // fsqrt.s
+		if rs2 != 0 {
+			m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+			return
+		}
+		inst, stringOperacao = "fsqrt.s", fmt.Sprintf("sqrt(0x%08x)", a)
+		operandos = fmt.Sprintf("%s,%s", fLabel[rd], fLabel[rs1])
+		resultado, flags = fSqrt(a, rm)
+	case 0b0010000: // Injeção de sinal
+		switch funct3 {
+		case 0b000: // fsgnj.s
+			inst, resultado = "fsgnj.s", a&^sinalF32|b&sinalF32
+		case 0b001: // fsgnjn.s
+			inst, resultado = "fsgnjn.s", a&^sinalF32|^b&sinalF32
+		case 0b010: // fsgnjx.s
+			inst, resultado = "fsgnjx.s", a^b&sinalF32
+		default:
+			m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+			return
+		}
+		stringOperacao = fmt.Sprintf("sgn(0x%08x,0x%08x)", a, b)
+	case 0b0010100: // fmin.s / fmax.s
+		switch funct3 {
+		case 0b000:
+			inst, stringOperacao = "fmin.s", fmt.Sprintf("min(0x%08x,0x%08x)", a, b)
+			resultado, flags = fMinMax(a, b, false)
+		case 0b001:
+			inst, stringOperacao = "fmax.s", fmt.Sprintf("max(0x%08x,0x%08x)", a, b)
+			resultado, flags = fMinMax(a, b, true)
+		default:
+			m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+			return
+		}
+	case 0b1010000: // Comparações
+		var verdadeiro bool
+		switch funct3 {
+		case 0b010:
+			inst, stringOperacao = "feq.s", fmt.Sprintf("(0x%08x==0x%08x)", a, b)
+		case 0b001:
+			inst, stringOperacao = "flt.s", fmt.Sprintf("(0x%08x<0x%08x)", a, b)
+		case 0b000:
+			inst, stringOperacao = "fle.s", fmt.Sprintf("(0x%08x<=0x%08x)", a, b)
+		default:
+			m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+			return
+		}
+		verdadeiro, flags = fComparar(a, b, funct3)
+		if verdadeiro {
+			resultado = 1
+		}
+		destinoX = true
+		operandos = fmt.Sprintf("%s,%s,%s", xLabel[rd], fLabel[rs1], fLabel[rs2])
+	case 0b1100000: // fcvt.w.s / fcvt.wu.s
+		if rs2 > 1 {
+			m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+			return
+		}
+		inst = "fcvt.w.s"
+		if rs2 == 1 {
+			inst = "fcvt.wu.s"
+		}
+		stringOperacao = fmt.Sprintf("(int)0x%08x", a)
+		resultado, flags = fParaInteiro(a, rs2 == 1, rm)
+		destinoX = true
+		operandos = fmt.Sprintf("%s,%s", xLabel[rd], fLabel[rs1])
+	case 0b1101000: // fcvt.s.w / fcvt.s.wu
+		if rs2 > 1 {
+			m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+			return
+		}
+		inst = "fcvt.s.w"
+		if rs2 == 1 {
+			inst = "fcvt.s.wu"
+		}
+		stringOperacao = fmt.Sprintf("(float)0x%08x", uint32(x[rs1]))
+		resultado, flags = fDeInteiro(uint32(x[rs1]), rs2 == 1, rm)
+		operandos = fmt.Sprintf("%s,%s", fLabel[rd], xLabel[rs1])
+	case 0b1110000: // fmv.x.w / fclass.s
+		if rs2 != 0 {
+			m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+			return
+		}
+		switch funct3 {
+		case 0b000: // fmv.x.w copia os bits sem verificar o encaixotamento
+			inst, resultado = "fmv.x.w", uint32(m.F[rs1])
+			stringOperacao = fmt.Sprintf("0x%08x", resultado)
+		case 0b001:
+			inst, stringOperacao = "fclass.s", fmt.Sprintf("class(0x%08x)", a)
+			resultado = fClass(a)
+		default:
+			m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+			return
+		}
+		destinoX = true
+		operandos = fmt.Sprintf("%s,%s", xLabel[rd], fLabel[rs1])
+	case 0b1111000: // fmv.w.x
+		if rs2 != 0 || funct3 != 0 {
+			m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+			return
+		}
+		inst, resultado = "fmv.w.x", uint32(x[rs1])
+		stringOperacao = fmt.Sprintf("0x%08x", resultado)
+		operandos = fmt.Sprintf("%s,%s", fLabel[rd], xLabel[rs1])
+	default:
+		m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+		return
+	}
+
+	fmt.Fprintf(writer, "%s:%-7s%s%s   %s -> 0x%08x\n", m.rotuloPC(pc), m.nomeInst(inst), operandos, sufixoRm, stringOperacao, resultado)
+	m.acumularFlags(flags)
+	if destinoX {
+		if rd != 0 {
+			x[rd] = int32(resultado)
+		}
+	} else {
+		m.escreverF(rd, resultado)
+	}
+}
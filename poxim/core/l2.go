@@ -0,0 +1,143 @@
+package core
+
+import "fmt"
+
+// A L2 fica entre as caches de primeiro nível e a memória: as faltas da
+// icache e da dcache são atendidas por ela, que por sua vez carrega os
+// blocos da memória. Ela é de escrita direta sem alocação: as escritas que
+// chegam à memória (stores em write-through ou blocos devolvidos pela
+// dcache em write-back) também atualizam a cópia da L2, quando houver, e
+// por isso os seus blocos nunca ficam sujos. Os eventos no rastro são
+// l2rh/l2rm nas leituras, l2wh/l2wm nas escritas e iinv/dinv nas
+// invalidações feitas pela L2 inclusiva.
+
+// ValidateL2 valida a geometria da L2 e a sua relação com as caches de
+// primeiro nível: o bloco da L2 não pode ser menor que o de nenhuma delas.
+// Com um bloco menor, o preenchimento de um bloco da L1 lê vários blocos
+// da L2 e pode substituir um deles no meio, deixando na L1 dados que a
+// L2 inclusiva não guarda mais.
+func ValidateL2(l2 CacheConfig, l1 ...CacheConfig) error {
+	if err := l2.Validate(); err != nil {
+		return err
+	}
+	for _, c := range l1 {
+		if l2.BlockSize < c.BlockSize {
+			return fmt.Errorf("bloco da L2 de %d bytes menor que o bloco de %d bytes da L1", l2.BlockSize, c.BlockSize)
+		}
+	}
+	return nil
+}
+
+// lerDaL2 preenche bloco com as palavras a partir de blockAddr lidas da
+// L2, com um acesso para cada bloco da L2 coberto.
+func (m *Machine) lerDaL2(blockAddr uint32, bloco []uint32) {
+	l2 := m.L2
+	fim := blockAddr + uint32(4*len(bloco))
+	for address := blockAddr; address < fim; {
+		tag, index, offset := l2.extractAddressFields(address)
+		l2.accesses++
+		inicio := m.ciclos
+		m.gastar(m.esperaAcerto(l2))
+
+		via := l2.buscarVia(tag, index)
+		if via >= 0 {
+			l2.hits++
+			l2.atualizarLRU(index, via)
+			l2.politica.acessar(l2, index, via)
+			m.logHit(l2, "l2rh", address, index, via, offset)
+		} else {
+			l2.misses++
+			m.contarEvento(HPM_EVENT_L2_MISS)
+			m.logMiss(l2, "l2rm", address, index, true)
+			m.loadBlockToCache(l2, address)
+			via = l2.buscarVia(tag, index)
+		}
+		m.medir(l2, inicio)
+
+		// Copiar a parte do bloco da L2 que cai no bloco pedido
+		for ; address < fim && offset < uint32(l2.blockWords); address, offset = address+4, offset+1 {
+			bloco[(address-blockAddr)/4] = l2.sets[index].data[via][offset]
+		}
+	}
+}
+
+// escreverNaL2 atualiza tam bytes, dentro de uma palavra, na cópia da L2
+// do bloco de endereco, se ele estiver presente.
+func (m *Machine) escreverNaL2(endereco, tam, valor uint32) {
+	index, via := m.acessarEscritaL2(endereco)
+	if via < 0 {
+		return
+	}
+	_, _, offset := m.L2.extractAddressFields(endereco)
+	desloc := 8 * (endereco & 0x3)
+	mascara := mascaraBytes(tam) << desloc
+	palavra := &m.L2.sets[index].data[via][offset]
+	*palavra = *palavra&^mascara | valor<<desloc&mascara
+	m.logHit(m.L2, "l2wh", endereco, index, via, offset)
+}
+
+// escreverBlocoNaL2 atualiza as palavras de um bloco devolvido pela dcache
+// nos blocos da L2 que estiverem presentes.
+func (m *Machine) escreverBlocoNaL2(blockAddr uint32, bloco []uint32) {
+	l2 := m.L2
+	fim := blockAddr + uint32(4*len(bloco))
+	for address := blockAddr; address < fim; {
+		index, via := m.acessarEscritaL2(address)
+		_, _, offset := l2.extractAddressFields(address)
+		inicio := address
+		for ; address < fim && offset < uint32(l2.blockWords); address, offset = address+4, offset+1 {
+			if via >= 0 {
+				l2.sets[index].data[via][offset] = bloco[(address-blockAddr)/4]
+			}
+		}
+		if via >= 0 {
+			_, _, offset = l2.extractAddressFields(inicio)
+			m.logHit(l2, "l2wh", inicio, index, via, offset)
+		}
+	}
+}
+
+// acessarEscritaL2 conta um acesso de escrita à L2 e retorna a via que
+// guarda endereco, ou -1 numa falta, que é registrada e não aloca.
+func (m *Machine) acessarEscritaL2(endereco uint32) (uint32, int) {
+	l2 := m.L2
+	tag, index, _ := l2.extractAddressFields(endereco)
+	l2.accesses++
+
+	via := l2.buscarVia(tag, index)
+	if via < 0 {
+		l2.misses++
+		m.contarEvento(HPM_EVENT_L2_MISS)
+		m.logMiss(l2, "l2wm", endereco, index, false)
+		return index, -1
+	}
+	l2.hits++
+	l2.atualizarLRU(index, via)
+	l2.politica.acessar(l2, index, via)
+	return index, via
+}
+
+// invalidarCopiasL1 invalida nas caches de primeiro nível os blocos que se
+// sobrepõem a [blockAddr, blockAddr+tam), substituído na L2 inclusiva.
+// Blocos sujos da dcache são escritos de volta antes.
+func (m *Machine) invalidarCopiasL1(blockAddr, tam uint32) {
+	for _, l1 := range []struct {
+		cache  *Cache
+		evento string
+	}{{m.ICache, "iinv"}, {m.DCache, "dinv"}} {
+		cache := l1.cache
+		bloco := uint32(cache.blockWords * 4)
+		for address := blockAddr &^ (bloco - 1); address < blockAddr+tam; address += bloco {
+			tag, index, _ := cache.extractAddressFields(address)
+			via := cache.buscarVia(tag, index)
+			if via < 0 {
+				continue
+			}
+			if cache.sets[index].dirty[via] {
+				m.escreverDeVolta(cache, index, via)
+			}
+			cache.sets[index].valid[via] = false
+			fmt.Fprintf(m.trace, "#cache_mem:%s 0x%08x    line=%d, id=0x%06x\n", l1.evento, address, index, tag)
+		}
+	}
+}
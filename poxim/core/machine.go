@@ -0,0 +1,509 @@
+// Package core implementa o simulador RISC-V (RV32IM) compartilhado pelas
+// versões v1, v2 e v3 do poxim. Cada versão é apenas um perfil (Config)
+// sobre a mesma Machine.
+package core
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// Endereço base e tamanho padrão da memória principal
+const (
+	RAMBase uint32 = 0x80000000
+	RAMSize        = 32 * 1024
+)
+
+// Config descreve quais recursos uma versão do simulador habilita.
+type Config struct {
+	// Traps habilita CSRs, exceções e interrupções (v2 e v3). Sem ela,
+	// instruções ilegais são ignoradas e acessos inválidos encerram a
+	// execução com erro, como na v1.
+	Traps bool
+	// RastroResultado acrescenta " -> 0x..." às linhas dos tipos R e I.
+	RastroResultado bool
+	// Caches habilita as caches de instruções e de dados (v3).
+	Caches bool
+	// ICache e DCache definem a geometria de cada cache; o valor zero
+	// usa DefaultCacheConfig.
+	ICache, DCache CacheConfig
+	// L2 define a cache de segundo nível compartilhada pelas duas caches
+	// acima; o tamanho zero a desabilita e a geometria deve passar por
+	// ValidateL2. A L2 é sempre de escrita direta e L2Inclusiva faz com
+	// que a substituição de um bloco na L2 invalide as suas cópias nas
+	// caches de primeiro nível.
+	L2          CacheConfig
+	L2Inclusiva bool
+	// ExtA habilita as instruções atômicas (RV32A).
+	ExtA bool
+	// ExtF habilita a precisão simples em ponto flutuante (RV32F).
+	ExtF bool
+	// RazaoMtime é o número de instruções retiradas por incremento de
+	// mtime no CLINT, mapeado quando Traps está habilitada (0 = 1).
+	RazaoMtime uint32
+	// AnotarSimbolos acrescenta <função+deslocamento> ao pc de cada linha
+	// do rastro, aos alvos de desvios e às entradas de trap, se o programa
+	// tiver símbolos.
+	AnotarSimbolos bool
+	// ModeloTempo habilita a contagem de ciclos com a tabela Latencias
+	// (o valor zero usa DefaultLatencies): mcycle passa a refletir os
+	// ciclos e o relatório final traz ciclos, instruções, CPI e o AMAT de
+	// cada cache.
+	ModeloTempo bool
+	Latencias   Latencies
+	// Preditor escolhe o preditor de desvios condicionais (static, btfn,
+	// 1bit, 2bit ou gshare); vazio desabilita a predição. BitsPreditor é
+	// o número de bits de índice das tabelas (0 = BitsPreditorPadrao) e
+	// BitsBTB o da BTB consultada por jal e jalr (0 = sem BTB). Com o
+	// modelo de tempo, a penalidade de desvio passa a ser cobrada apenas
+	// nas predições erradas.
+	Preditor              string
+	BitsPreditor, BitsBTB int
+	// AnotarPredicao acrescenta a predição às linhas de desvio do rastro.
+	AnotarPredicao bool
+	// Pipeline habilita o modelo de pipeline de cinco estágios, que conta
+	// os ciclos e as bolhas por causa sem alterar a execução. Forwarding
+	// habilita o adiantamento de resultados para o EX.
+	Pipeline, Forwarding bool
+}
+
+// ProfileV1 retorna a configuração da v1: RV32IM sem CSRs nem traps.
+func ProfileV1() Config {
+	return Config{}
+}
+
+// ProfileV2 retorna a configuração da v2: v1 com CSRs, exceções,
+// interrupções e as extensões A e F.
+func ProfileV2() Config {
+	return Config{Traps: true, RastroResultado: true, ExtA: true, ExtF: true}
+}
+
+// ProfileV3 retorna a configuração da v3: v2 com caches de instruções e dados.
+func ProfileV3() Config {
+	return Config{Traps: true, RastroResultado: true, Caches: true, ExtA: true, ExtF: true}
+}
+
+// Machine guarda o estado arquitetural de um hart RV32.
+type Machine struct {
+	X   []int32           // Registradores inteiros
+	F   []uint64          // Registradores de ponto flutuante (NaN-boxed)
+	PC  uint32            // Contador de programa
+	Mem []byte            // Memória principal, mapeada a partir de RAMBase
+	CSR map[uint32]uint32 // Registradores de controle e status
+
+	// Bus atende todos os acessos à memória; a RAM ocupa a região
+	// [RAMBase, RAMBase+len(Mem)) e outros dispositivos podem ser mapeados
+	// com Bus.Attach.
+	Bus *Bus
+	// CLINT é o temporizador do hart (nil sem Traps).
+	CLINT *CLINT
+
+	ICache *Cache // Cache de instruções (nil se desabilitada)
+	DCache *Cache // Cache de dados (nil se desabilitada)
+	L2     *Cache // Cache de segundo nível (nil se desabilitada)
+
+	// Halted indica que a execução terminou (ebreak ou erro).
+	Halted bool
+
+	cfg        Config
+	trace      io.Writer
+	proximoPC  uint32
+	privilegio uint32 // Nível de privilégio atual (PRIV_U, PRIV_S ou PRIV_M)
+	nomeC      string // Mnemônico da instrução comprimida em execução
+	err        error
+
+	simbolos  tabelaSimbolos // Símbolos do ELF carregado
+	segmentos []Segment      // Faixas preenchidas pelo programa carregado
+
+	// Modelo de tempo: ciclos decorridos e instruções executadas
+	ciclos     uint64
+	instrucoes uint64
+
+	// Preditor de desvios (nil se desabilitado) e o próximo pc previsto
+	// para a instrução em execução
+	predicao   *predicao
+	pcPrevisto uint32
+
+	pipeline *pipeline // Modelo de pipeline (nil se desabilitado)
+
+	// Contadores de desempenho (ver contadores.go)
+	contadores          [32]uint64
+	contadoresEscritos  uint32 // Escritos pela instrução em execução
+	inibidos            uint32 // mcountinhibit
+	eventosHPM          [32]uint32
+	contadoresPorEvento [numEventosHPM]uint32
+	trapNaInstrucao     bool
+
+	// Conjunto de reserva de lr.w/sc.w
+	reserva       uint32
+	reservaValida bool
+}
+
+// New cria uma máquina com a configuração dada. O rastro de execução é
+// escrito em trace; se trace for nil, ele é descartado.
+func New(cfg Config, trace io.Writer) *Machine {
+	if trace == nil {
+		trace = io.Discard
+	}
+	m := &Machine{
+		X:     make([]int32, 32),
+		F:     make([]uint64, 32),
+		PC:    RAMBase,
+		Mem:   make([]byte, RAMSize),
+		CSR:   make(map[uint32]uint32),
+		Bus:   &Bus{},
+		cfg:   cfg,
+		trace: trace,
+
+		privilegio: PRIV_M,
+	}
+	if cfg.ModeloTempo && cfg.Latencias == (Latencies{}) {
+		m.cfg.Latencias = DefaultLatencies()
+	}
+	if !cfg.ModeloTempo {
+		m.cfg.Latencias = Latencies{} // Nenhum ciclo é contado
+	}
+	m.Bus.Attach("ram", RAMBase, RAMSize, &RAM{Data: m.Mem})
+
+	if cfg.Traps {
+		m.CSR[MSTATUS] = MSTATUS_MPP
+		if cfg.ExtF {
+			m.CSR[MSTATUS] |= MSTATUS_FS_INIT
+		}
+		m.CSR[MISA] = valorMisa(cfg)
+		m.CSR[MTVEC] = 0
+		m.CSR[MIE] = 0
+		m.CSR[MIP] = 0 // Inicializa o Machine Interrupt Pending
+		m.CLINT = NewCLINT(cfg.RazaoMtime, m.SetIRQ)
+		m.Bus.Attach("clint", CLINTBase, CLINTSize, m.CLINT)
+	}
+	if cfg.ExtF {
+		m.CSR[FCSR] = 0
+	}
+	if cfg.Preditor != "" {
+		m.predicao = novaPredicao(cfg)
+	}
+	if cfg.Pipeline {
+		m.pipeline = novoPipeline(cfg.Forwarding)
+	}
+	if cfg.Caches {
+		icache, dcache := geometria(cfg.ICache), geometria(cfg.DCache)
+		m.ICache = NewCache(icache)
+		m.DCache = NewCache(dcache)
+		if cfg.L2.Size != 0 {
+			if err := ValidateL2(cfg.L2, icache, dcache); err != nil {
+				panic(err)
+			}
+			m.L2 = NewCache(cfg.L2)
+		}
+	}
+	return m
+}
+
+// geometria completa uma configuração de cache sem tamanho com a
+// geometria padrão, mantendo a política escolhida.
+func geometria(c CacheConfig) CacheConfig {
+	if c.Size == 0 {
+		padrao := DefaultCacheConfig()
+		c.Size, c.BlockSize, c.Assoc = padrao.Size, padrao.BlockSize, padrao.Assoc
+	}
+	return c
+}
+
+// Config retorna a configuração com que a máquina foi criada.
+func (m *Machine) Config() Config {
+	return m.cfg
+}
+
+// SetIRQ marca ou limpa em mip os bits de interrupção em bit. É a linha de
+// interrupção usada pelos dispositivos do barramento.
+func (m *Machine) SetIRQ(bit uint32, pendente bool) {
+	if pendente {
+		m.CSR[MIP] |= bit
+	} else {
+		m.CSR[MIP] &^= bit
+	}
+}
+
+// Step executa uma única instrução, ou desvia para o tratador caso haja
+// uma interrupção habilitada e pendente.
+func (m *Machine) Step() error {
+	if m.Halted {
+		return m.err
+	}
+	m.X[0] = 0
+
+	inicio := m.ciclos
+	m.trapNaInstrucao = false
+	defer func() {
+		ciclos := m.ciclos - inicio
+		if !m.cfg.ModeloTempo {
+			ciclos = 1
+		}
+		m.avancarContadores(ciclos, !m.trapNaInstrucao)
+		// Os dispositivos avançam junto com instret: passos que terminam
+		// em trap, inclusive as entradas em interrupção, não contam
+		if !m.trapNaInstrucao {
+			m.Bus.tick()
+		}
+	}()
+
+	if m.cfg.Traps && m.verificarInterrupcoes() {
+		m.gastar(m.cfg.Latencias.Branch)
+		if m.pipeline != nil {
+			m.pipeline.interromper()
+		}
+		return nil
+	}
+
+	instrucao, tamanho, ok := m.lerInstrucao(m.PC)
+	if !ok {
+		m.proximoPC = m.PC
+		m.excecao(EXC_INSTRUCTION_ACCESS_FAULT, m.PC)
+		m.PC = m.proximoPC
+		return m.err
+	}
+
+	m.proximoPC = m.PC + tamanho
+	m.pcPrevisto = m.proximoPC
+	m.nomeC = ""
+	if tamanho == 2 {
+		expandida, nome, valida := expandirComprimida(instrucao)
+		if !valida {
+			m.excecao(EXC_ILLEGAL_INSTRUCTION, instrucao)
+			m.PC = m.proximoPC
+			return m.err
+		}
+		instrucao, m.nomeC = expandida, nome
+	}
+	m.executar(instrucao)
+	m.instrucoes++
+	m.gastar(m.cfg.Latencias.latenciaClasse(instrucao))
+	if m.proximoPC != m.pcPrevisto {
+		m.gastar(m.cfg.Latencias.Branch)
+	}
+	if m.pipeline != nil {
+		m.pipeline.emitir(m.PC, instrucao, m.proximoPC != m.pcPrevisto)
+	}
+	m.PC = m.proximoPC
+	return m.err
+}
+
+// Run executa até um ebreak ou erro e, ao final, escreve as estatísticas
+// no rastro.
+func (m *Machine) Run() error {
+	for !m.Halted {
+		if err := m.Step(); err != nil {
+			return err
+		}
+	}
+	m.WriteStats()
+	return nil
+}
+
+// WriteStats escreve no rastro as estatísticas finais das caches e, com o
+// modelo de tempo, o relatório de ciclos.
+func (m *Machine) WriteStats() {
+	if m.ICache != nil && m.DCache != nil {
+		m.escreverEstatisticasCache()
+	}
+	if m.predicao != nil {
+		m.escreverEstatisticasPredicao()
+	}
+	if m.pipeline != nil {
+		m.escreverEstatisticasPipeline()
+	}
+	if m.cfg.ModeloTempo {
+		m.escreverRelatorioTempo()
+	}
+}
+
+// escreverEstatisticasCache escreve a taxa de acerto de cada cache.
+func (m *Machine) escreverEstatisticasCache() {
+	icacheHitRate := float64(m.ICache.hits) / float64(m.ICache.accesses)
+	dcacheHitRate := float64(m.DCache.hits) / float64(m.DCache.accesses)
+	fmt.Fprintf(m.trace, "#cache_mem:istats    hit=%.4f\n", icacheHitRate)
+	if m.DCache.writeBack {
+		fmt.Fprintf(m.trace, "#cache_mem:dstats    hit=%.4f, writebacks=%d\n", dcacheHitRate, m.DCache.writebacks)
+	} else {
+		fmt.Fprintf(m.trace, "#cache_mem:dstats    hit=%.4f\n", dcacheHitRate)
+	}
+	if m.L2 != nil {
+		fmt.Fprintf(m.trace, "#cache_mem:l2stats   hit=%.4f\n", float64(m.L2.hits)/float64(m.L2.accesses))
+	}
+}
+
+// verificarInterrupcoes desvia para o tratador se houver interrupções
+// habilitadas e pendentes. As interrupções não delegadas em mideleg vão
+// para o modo de máquina e estão sempre habilitadas abaixo dele; as
+// delegadas vão para o supervisor e nunca interrompem o modo de máquina.
+func (m *Machine) verificarInterrupcoes() bool {
+	csr := m.CSR
+	interrupcoesPendentes := csr[MIE] & csr[MIP]
+	if interrupcoesPendentes == 0 {
+		return false
+	}
+
+	var habilitadas uint32
+	if (csr[MSTATUS]&MSTATUS_MIE_BIT) != 0 || m.privilegio < PRIV_M {
+		habilitadas |= ^csr[MIDELEG]
+	}
+	if (csr[MSTATUS]&MSTATUS_SIE_BIT) != 0 && m.privilegio == PRIV_S || m.privilegio < PRIV_S {
+		habilitadas |= csr[MIDELEG]
+	}
+	interrupcoesPendentes &= habilitadas
+
+	var interruptCode uint32
+	encontrada := false
+	for _, codigo := range prioridadeInterrupcoes {
+		if interrupcoesPendentes&(1<<codigo) != 0 {
+			interruptCode, encontrada = codigo, true
+			break
+		}
+	}
+	if !encontrada {
+		return false
+	}
+
+	m.gerarExcecao(interruptCode, 0, true)
+	m.PC = m.proximoPC
+	return true
+}
+
+// excecao gera uma exceção síncrona para a instrução atual. Sem traps
+// (v1), instruções ilegais são ignoradas e as demais exceções encerram a
+// execução.
+func (m *Machine) excecao(codigoTrap, valorTrap uint32) {
+	if !m.cfg.Traps {
+		if codigoTrap != EXC_ILLEGAL_INSTRUCTION {
+			m.err = fmt.Errorf("%s em 0x%08x (tval=0x%08x)", exceptionNames[codigoTrap], m.PC, valorTrap)
+			m.Halted = true
+		}
+		return
+	}
+	m.gerarExcecao(codigoTrap, valorTrap, false)
+}
+
+// camposTrap reúne os CSRs e os bits de mstatus usados por um nível de
+// privilégio para receber traps e retornar deles.
+type camposTrap struct {
+	nivel                  uint32
+	epc, causa, tval, tvec uint32
+	ie, pie, pp            uint32 // Bits de mstatus
+}
+
+var (
+	trapMaquina    = camposTrap{PRIV_M, MEPC, MCAUSE, MTVAL, MTVEC, MSTATUS_MIE_BIT, MSTATUS_MPIE_BIT, MSTATUS_MPP}
+	trapSupervisor = camposTrap{PRIV_S, SEPC, SCAUSE, STVAL, STVEC, MSTATUS_SIE_BIT, MSTATUS_SPIE_BIT, MSTATUS_SPP}
+)
+
+// gerarExcecao salva o estado do trap nos CSRs e desvia para mtvec, ou
+// para stvec se o trap ocorreu abaixo do modo de máquina e está delegado
+// em medeleg ou mideleg.
+func (m *Machine) gerarExcecao(codigoTrap, valorTrap uint32, isInterrupt bool) {
+	csr := m.CSR
+
+	// Traps invalidam qualquer reserva pendente de lr.w
+	m.reservaValida = false
+	m.trapNaInstrucao = true
+
+	delegacao := csr[MEDELEG]
+	if isInterrupt {
+		delegacao = csr[MIDELEG]
+	}
+	c := trapMaquina
+	if m.privilegio < PRIV_M && delegacao&(1<<codigoTrap) != 0 {
+		c = trapSupervisor
+	}
+
+	// Salva o PC atual e define a causa
+	csr[c.epc] = m.PC
+	csr[c.tval] = valorTrap
+
+	if isInterrupt {
+		csr[c.causa] = (1 << 31) | codigoTrap // Bit 31 setado para interrupções
+	} else {
+		csr[c.causa] = codigoTrap
+	}
+
+	// Desabilita interrupções globais e salva o estado anterior
+	if (csr[MSTATUS] & c.ie) != 0 {
+		csr[MSTATUS] |= c.pie // Salva xIE em xPIE
+	} else {
+		csr[MSTATUS] &^= c.pie
+	}
+	csr[MSTATUS] &^= c.ie // Desabilita xIE
+	// Salva o nível de privilégio em xPP; o tratador roda no nível do trap
+	csr[MSTATUS] = csr[MSTATUS]&^c.pp | m.privilegio<<bits.TrailingZeros32(c.pp)
+	anterior := m.privilegio
+	m.privilegio = c.nivel
+
+	var eventName string
+	var eventType string
+
+	if isInterrupt {
+		eventType = "interrupt"
+		var ok bool
+		eventName, ok = interruptNames[codigoTrap]
+		if !ok {
+			eventName = "Unknown Interrupt"
+		}
+	} else {
+		eventType = "exception"
+		var ok bool
+		eventName, ok = exceptionNames[codigoTrap]
+		if !ok {
+			eventName = "Unknown Exception"
+		}
+	}
+
+	// Pula para o endereço do tratador de trap: no modo vetorizado, as
+	// interrupções vão para BASE+4*causa e o alvo aparece no rastro
+	m.proximoPC = csr[c.tvec] &^ MTVEC_MODE
+	alvo := ""
+	if isInterrupt && csr[c.tvec]&MTVEC_MODE == MTVEC_VETORIZADO {
+		m.proximoPC += 4 * codigoTrap
+		alvo = fmt.Sprintf(",target=0x%08x", m.proximoPC)
+	}
+
+	fmt.Fprintf(m.trace, ">%s:%s 			cause=0x%08x,epc=0x%08x,tval=0x%08x%s%s\n", eventType, eventName, csr[c.causa], csr[c.epc], csr[c.tval], alvo, m.anotar(csr[c.epc]))
+	if anterior != m.privilegio {
+		m.marcarPrivilegio()
+	}
+}
+
+// retornarDeTrap executa mret ou sret: restaura xIE de xPIE, volta ao
+// nível salvo em xPP, que passa ao modo de usuário, e desvia para xepc.
+func (m *Machine) retornarDeTrap(c camposTrap) {
+	csr := m.CSR
+	if (csr[MSTATUS] & c.pie) != 0 {
+		csr[MSTATUS] |= c.ie // Restaura xIE de xPIE
+	} else {
+		csr[MSTATUS] &^= c.ie
+	}
+	csr[MSTATUS] |= c.pie // Seta xPIE
+	nivel := (csr[MSTATUS] & c.pp) >> bits.TrailingZeros32(c.pp)
+	csr[MSTATUS] &^= c.pp
+	m.mudarPrivilegio(nivel)
+	m.proximoPC = csr[c.epc]
+}
+
+// mudarPrivilegio passa ao nível de privilégio dado, marcando a mudança
+// no rastro.
+func (m *Machine) mudarPrivilegio(nivel uint32) {
+	if nivel != m.privilegio {
+		m.privilegio = nivel
+		m.marcarPrivilegio()
+	}
+}
+
+// marcarPrivilegio escreve no rastro o nível de privilégio atual.
+func (m *Machine) marcarPrivilegio() {
+	fmt.Fprintf(m.trace, ">privilege:%s\n", privilegeNames[m.privilegio])
+}
+
+// Privilege retorna o nível de privilégio atual (PRIV_U, PRIV_S ou PRIV_M).
+func (m *Machine) Privilege() uint32 {
+	return m.privilegio
+}
@@ -0,0 +1,301 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Load carrega na memória um programa no formato texto "@endereço" seguido
+// de bytes em hexadecimal, ou um executável ELF32 RISC-V, reconhecido pela
+// assinatura. Um ELF também define o PC inicial (e_entry).
+func (m *Machine) Load(caminhoArquivo string) error {
+	arquivo, err := os.Open(caminhoArquivo)
+	if err != nil {
+		return fmt.Errorf("falha ao abrir o arquivo de entrada: %w", err)
+	}
+	defer arquivo.Close()
+
+	cabecalho := make([]byte, 4)
+	n, _ := io.ReadFull(arquivo, cabecalho)
+	if ehELF(cabecalho[:n]) {
+		return m.carregarELF(arquivo)
+	}
+	if _, err := arquivo.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("falha ao ler o arquivo de entrada: %w", err)
+	}
+	m.segmentos, err = carregarMemoria(arquivo, m.Mem, RAMBase)
+	return err
+}
+
+// Segment é uma faixa de endereços preenchida pelo programa carregado.
+type Segment struct {
+	Addr, Size uint32
+	// Exec indica um segmento de código; no formato texto, que não
+	// distingue código de dados, todos são.
+	Exec bool
+}
+
+// Segments retorna os segmentos carregados por Load, em ordem de leitura.
+func (m *Machine) Segments() []Segment {
+	return m.segmentos
+}
+
+// carregarMemoria lê o formato texto para mem, que começa no endereço
+// offset, e retorna as faixas contíguas de bytes lidas dentro de mem.
+func carregarMemoria(arquivo io.Reader, mem []byte, offset uint32) ([]Segment, error) {
+	var segmentos []Segment
+	scanner := bufio.NewScanner(arquivo)
+	var endereco uint32 = 0
+	for scanner.Scan() {
+		linha := strings.TrimSpace(scanner.Text())
+		if linha == "" {
+			continue
+		}
+
+		if strings.HasPrefix(linha, "@") {
+			addr, err := strconv.ParseUint(linha[1:], 16, 32)
+			if err != nil {
+				return nil, fmt.Errorf("endereço inválido: %s", linha)
+			}
+			endereco = uint32(addr)
+		} else {
+			stringsDeBytes := strings.Fields(linha)
+			for _, stringDoByte := range stringsDeBytes {
+				valorDoByte, err := strconv.ParseUint(stringDoByte, 16, 8)
+				if err != nil {
+					return nil, fmt.Errorf("byte inválido: %s", stringDoByte)
+				}
+				idxMem := endereco - offset
+				if idxMem < uint32(len(mem)) {
+					mem[idxMem] = byte(valorDoByte)
+					if n := len(segmentos); n > 0 && segmentos[n-1].Addr+segmentos[n-1].Size == endereco {
+						segmentos[n-1].Size++
+					} else {
+						segmentos = append(segmentos, Segment{Addr: endereco, Size: 1, Exec: true})
+					}
+				}
+				endereco++
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("erro ao ler o arquivo de entrada: %w", err)
+	}
+	return segmentos, nil
+}
+
+// naRAM indica se o acesso de tam bytes em endereco cabe na memória
+// principal, a única região cacheável.
+func (m *Machine) naRAM(endereco, tam uint32) bool {
+	return endereco >= RAMBase && endereco-RAMBase+tam <= uint32(len(m.Mem))
+}
+
+// lerInstrucao busca a instrução no endereço pc. Se os dois bits menos
+// significativos não forem 11, a instrução é comprimida (RV32C) e apenas 16
+// bits são retornados; tamanho indica quantos bytes foram consumidos.
+func (m *Machine) lerInstrucao(pc uint32) (instrucao uint32, tamanho uint32, ok bool) {
+	if !m.Bus.Mapped(pc, 2) {
+		return 0, 0, false // Falha de acesso à instrução
+	}
+
+	if pc&0x3 == 0 && m.Bus.Mapped(pc, 4) {
+		palavra := m.buscarPalavra(pc)
+		if palavra&0x3 != 0x3 {
+			return palavra & 0xFFFF, 2, true
+		}
+		return palavra, 4, true
+	}
+
+	// PC alinhado em meia palavra, ou na última meia palavra da região: a
+	// metade superior só é buscada se a instrução tiver 32 bits
+	baixa := m.buscarMeiaPalavra(pc)
+	if baixa&0x3 != 0x3 {
+		return baixa, 2, true
+	}
+	if !m.Bus.Mapped(pc+2, 2) {
+		return 0, 0, false
+	}
+	alta := m.buscarMeiaPalavra(pc + 2)
+	return baixa | alta<<16, 4, true
+}
+
+// buscarMeiaPalavra lê a meia palavra em endereco pelo caminho de busca de
+// instruções. Se a palavra que a contém não couber inteira numa região,
+// apenas os 2 bytes são lidos do barramento.
+func (m *Machine) buscarMeiaPalavra(endereco uint32) uint32 {
+	if !m.Bus.Mapped(endereco&^0x3, 4) {
+		m.gastar(m.cfg.Latencias.Mem)
+		meia, _ := m.Bus.Read(endereco, 2)
+		return meia
+	}
+	return (m.buscarPalavra(endereco&^0x3) >> (8 * (endereco & 0x3))) & 0xFFFF
+}
+
+// buscarPalavra lê a palavra alinhada em endereco pelo caminho de busca de
+// instruções, passando pela cache de instruções quando habilitada. Apenas
+// a RAM é cacheável; as demais regiões são lidas direto do barramento.
+func (m *Machine) buscarPalavra(endereco uint32) uint32 {
+	if m.ICache != nil && m.naRAM(endereco, 4) {
+		defer m.medir(m.ICache, m.ciclos)
+
+		// Acessar cache de instruções
+		palavra, hit := m.accessICache(endereco)
+		if hit {
+			return palavra
+		}
+
+		// Cache miss - carregar bloco
+		m.loadBlockToCache(m.ICache, endereco)
+
+		// Tentar novamente
+		palavra, hit = m.accessICache(endereco)
+		if hit {
+			return palavra
+		}
+	}
+
+	// Acesso direto ao barramento
+	m.gastar(m.cfg.Latencias.Mem)
+	palavra, _ := m.Bus.Read(endereco, 4)
+	return palavra
+}
+
+// lerDados lê tam bytes em endereco pelo caminho de dados. O barramento
+// decide se o acesso é válido; na RAM o valor vem da cache de dados quando
+// habilitada, que guarda o bloco em palavras: um acesso desalinhado que
+// cruza a fronteira de palavra consulta as duas palavras.
+func (m *Machine) lerDados(endereco, tam uint32) (uint32, bool) {
+	valor, ok := m.Bus.Read(endereco, tam)
+	if !ok {
+		return 0, false
+	}
+	if m.DCache == nil || !m.naRAM(endereco, tam) {
+		m.gastar(m.cfg.Latencias.Mem)
+		return valor, true
+	}
+
+	desloc := endereco & 0x3
+	valor = m.lerPalavraCache(endereco) >> (8 * desloc)
+	if desloc+tam > 4 {
+		valor |= m.lerPalavraCache(endereco&^0x3+4) << (8 * (4 - desloc))
+	}
+	return valor & mascaraBytes(tam), true
+}
+
+// lerPalavraCache retorna a palavra que contém endereco, carregando o bloco
+// na cache de dados em caso de falta.
+func (m *Machine) lerPalavraCache(endereco uint32) uint32 {
+	defer m.medir(m.DCache, m.ciclos)
+	palavra, hit := m.accessDCacheRead(endereco)
+	if !hit {
+		// Cache miss - carregar bloco e tentar novamente
+		m.loadBlockToCache(m.DCache, endereco)
+		palavra, _ = m.accessDCacheRead(endereco)
+	}
+	return palavra
+}
+
+// escreverDados escreve os tam bytes menos significativos de valor em
+// endereco pelo caminho de dados. Com a cache em write-back a RAM só é
+// atualizada quando o bloco sai da cache; nos demais casos a escrita vai
+// ao barramento e, se o endereço for cacheável, também à cache.
+func (m *Machine) escreverDados(endereco, tam, valor uint32) bool {
+	cacheavel := m.DCache != nil && m.naRAM(endereco, tam)
+	if !cacheavel || !m.DCache.writeBack {
+		if !m.Bus.Write(endereco, tam, valor) {
+			return false
+		}
+	}
+	m.invalidarReserva(endereco, tam)
+	if !cacheavel {
+		m.gastar(m.cfg.Latencias.Mem)
+		return true
+	}
+
+	// Dividir o acesso nas palavras que ele toca
+	desloc := endereco & 0x3
+	n := min(tam, 4-desloc)
+	m.escreverPalavraCache(endereco, n, valor)
+	if n < tam {
+		m.escreverPalavraCache(endereco&^0x3+4, tam-n, valor>>(8*n))
+	}
+	return true
+}
+
+// escreverPalavraCache escreve tam bytes de uma palavra na cache de dados
+// e, em escrita direta, também na L2, se houver.
+func (m *Machine) escreverPalavraCache(endereco, tam, valor uint32) {
+	defer m.medir(m.DCache, m.ciclos)
+	m.accessDCacheWrite(endereco, tam, valor)
+	if !m.DCache.writeBack {
+		// A escrita direta espera a memória
+		m.gastar(m.cfg.Latencias.Mem)
+		if m.L2 != nil {
+			m.escreverNaL2(endereco, tam, valor)
+		}
+	}
+}
+
+// mascaraBytes retorna a máscara dos tam bytes menos significativos.
+func mascaraBytes(tam uint32) uint32 {
+	return uint32(uint64(1)<<(8*tam) - 1)
+}
+
+func estenderSinal(valor uint32, bits uint) int32 {
+	desloca := 32 - bits
+	return int32(valor<<desloca) >> desloca
+}
+
+// PeekMemory lê até tam bytes a partir de endereco como o depurador os vê:
+// sem passar pelos dispositivos, sem contar acessos nas caches e sem
+// escrever no rastro. Cada byte vem da cópia mais recente, um bloco sujo
+// da cache de dados, se houver, ou a RAM. A leitura para no primeiro byte
+// fora da RAM, e os bytes lidos até ali são retornados.
+func (m *Machine) PeekMemory(endereco, tam uint32) []byte {
+	dados := make([]byte, 0, tam)
+	for i := uint32(0); i < tam; i++ {
+		a := endereco + i
+		if !m.naRAM(a, 1) {
+			break
+		}
+		b := m.Mem[a-RAMBase]
+		if m.DCache != nil {
+			if palavra, suja, ok := m.DCache.palavraEm(a); ok && suja {
+				b = byte(*palavra >> (8 * (a & 0x3)))
+			}
+		}
+		dados = append(dados, b)
+	}
+	return dados
+}
+
+// PokeMemory escreve dados a partir de endereco como o depurador: altera a
+// RAM e todas as cópias dos bytes nas caches, sem contar acessos nem
+// escrever no rastro, e invalida a reserva de lr.w que os cubra. Os
+// dispositivos não são escritos: a escrita para no primeiro byte fora da
+// RAM. Retorna o número de bytes escritos.
+func (m *Machine) PokeMemory(endereco uint32, dados []byte) int {
+	for i, b := range dados {
+		a := endereco + uint32(i)
+		if !m.naRAM(a, 1) {
+			return i
+		}
+		m.Mem[a-RAMBase] = b
+		for _, cache := range []*Cache{m.ICache, m.DCache, m.L2} {
+			if cache == nil {
+				continue
+			}
+			if palavra, _, ok := cache.palavraEm(a); ok {
+				desloc := 8 * (a & 0x3)
+				*palavra = *palavra&^(0xFF<<desloc) | uint32(b)<<desloc
+			}
+		}
+		m.invalidarReserva(a, 1)
+	}
+	return len(dados)
+}
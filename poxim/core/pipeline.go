@@ -0,0 +1,245 @@
+package core
+
+import (
+	"fmt"
+	"io"
+)
+
+// O modelo de pipeline escalona, em ordem, as instruções já executadas
+// pelo modelo funcional num pipeline clássico IF/ID/EX/MEM/WB. Os
+// resultados arquiteturais são sempre os do modelo funcional; o pipeline
+// só calcula em que ciclo cada instrução ocupa cada estágio.
+//
+// Os operandos são lidos no ID; o banco de registradores é escrito na
+// primeira metade do ciclo de WB e lido na segunda. Com adiantamento, o
+// resultado de uma instrução da ULA chega ao EX da seguinte e o de um
+// load só ao fim do MEM, o que custa uma bolha (load-use). Desvios, saltos
+// e traps são resolvidos no EX: quando o fluxo muda para um pc diferente
+// do previsto (o seguinte, sem preditor de desvios), as duas instruções
+// buscadas em seguida são descartadas.
+
+// Estágios do pipeline
+const (
+	estIF = iota
+	estID
+	estEX
+	estMEM
+	estWB
+	numEstagios
+)
+
+var nomesEstagios = [numEstagios]string{"IF", "ID", "EX", "MEM", "WB"}
+
+// instrucaoPipeline guarda o primeiro e o último ciclo de uma instrução em
+// cada estágio.
+type instrucaoPipeline struct {
+	pc             uint32
+	entrada, saida [numEstagios]uint64
+}
+
+// pipeline é o estado do escalonamento.
+type pipeline struct {
+	forwarding bool
+	diagrama   io.Writer // nil sem diagrama
+
+	anterior     instrucaoPipeline // Última instrução escalonada
+	redirecionar bool              // A última instrução mudou o fluxo
+
+	// Primeiro ciclo em que o valor de cada registrador (x0-x31 e f0-f31)
+	// pode entrar no EX e se ele vem de um load
+	pronto [64]uint64
+	deLoad [64]bool
+
+	janela       []instrucaoPipeline // Instruções ainda não desenhadas
+	proximoCiclo uint64              // Próximo ciclo do diagrama
+
+	instrucoes                               uint64
+	bolhasRAW, bolhasLoadUso, bolhasControle uint64
+}
+
+func novoPipeline(forwarding bool) *pipeline {
+	return &pipeline{forwarding: forwarding, proximoCiclo: 1}
+}
+
+// SetPipelineDiagram faz o modelo de pipeline escrever em w, a cada
+// ciclo, o pc da instrução em cada estágio. Não tem efeito sem
+// Config.Pipeline.
+func (m *Machine) SetPipelineDiagram(w io.Writer) {
+	if m.pipeline != nil {
+		m.pipeline.diagrama = w
+	}
+}
+
+// emitir escalona a instrução executada em pc. redireciona indica que
+// o próximo pc não é o que foi previsto na busca.
+func (p *pipeline) emitir(pc, instrucao uint32, redireciona bool) {
+	ant := &p.anterior
+	var in instrucaoPipeline
+	in.pc = pc
+
+	// IF: entra depois da anterior e, após uma mudança de fluxo, só
+	// depois que ela for resolvida no EX. Fica no IF enquanto a anterior
+	// ocupar o ID.
+	busca := ant.saida[estIF] + 1
+	semDesvio := max(busca, ant.saida[estID])
+	if p.redirecionar {
+		busca = max(busca, ant.saida[estEX]+1)
+	}
+	in.entrada[estIF] = busca
+	in.saida[estIF] = max(busca, ant.saida[estID])
+	p.bolhasControle += in.saida[estIF] - semDesvio
+
+	// ID: espera a anterior deixar o EX e os operandos ficarem prontos
+	in.entrada[estID] = in.saida[estIF] + 1
+	livre := max(in.entrada[estID], ant.saida[estEX])
+	destino, fontes, load := operandosPipeline(instrucao)
+	espera, porLoad := livre, false
+	for _, r := range fontes {
+		if p.pronto[r] == 0 {
+			continue // Nunca escrito
+		}
+		// Último ciclo no ID para entrar no EX com o operando pronto
+		ultimo := p.pronto[r] - 1
+		if ultimo > espera || ultimo == espera && ultimo > livre && p.deLoad[r] {
+			espera, porLoad = ultimo, p.deLoad[r]
+		}
+	}
+	if espera > livre {
+		if porLoad {
+			p.bolhasLoadUso += espera - livre
+		} else {
+			p.bolhasRAW += espera - livre
+		}
+	}
+	in.saida[estID] = espera
+
+	// EX, MEM e WB levam um ciclo cada
+	for e := estEX; e < numEstagios; e++ {
+		in.entrada[e] = in.saida[e-1] + 1
+		in.saida[e] = in.entrada[e]
+	}
+
+	if destino > 0 {
+		switch {
+		case !p.forwarding:
+			p.pronto[destino] = in.saida[estWB] + 1
+		case load:
+			p.pronto[destino] = in.saida[estMEM] + 1
+		default:
+			p.pronto[destino] = in.saida[estEX] + 1
+		}
+		p.deLoad[destino] = load
+	}
+
+	p.instrucoes++
+	p.anterior = in
+	p.redirecionar = redireciona
+	p.janela = append(p.janela, in)
+	p.desenhar(in.entrada[estIF])
+}
+
+// interromper registra a entrada num tratador de interrupção entre duas
+// instruções: a busca seguinte é descartada como numa mudança de fluxo.
+func (p *pipeline) interromper() {
+	p.redirecionar = true
+}
+
+// desenhar escreve o diagrama até o ciclo ate, exclusive. Nenhuma
+// instrução escalonada depois pode ocupar esses ciclos.
+func (p *pipeline) desenhar(ate uint64) {
+	for ; p.proximoCiclo < ate; p.proximoCiclo++ {
+		c := p.proximoCiclo
+		if p.diagrama != nil {
+			fmt.Fprintf(p.diagrama, "%8d", c)
+			for e := 0; e < numEstagios; e++ {
+				ocupante := "-"
+				for _, in := range p.janela {
+					if in.entrada[e] <= c && c <= in.saida[e] {
+						ocupante = fmt.Sprintf("0x%08x", in.pc)
+					}
+				}
+				fmt.Fprintf(p.diagrama, "  %s:%-10s", nomesEstagios[e], ocupante)
+			}
+			fmt.Fprintln(p.diagrama)
+		}
+		// Descartar as instruções que já saíram do WB
+		for len(p.janela) > 0 && p.janela[0].saida[estWB] <= c {
+			p.janela = p.janela[1:]
+		}
+	}
+}
+
+// ciclos retorna o número de ciclos até o WB da última instrução.
+func (p *pipeline) ciclos() uint64 {
+	return p.anterior.saida[estWB]
+}
+
+// escreverEstatisticasPipeline completa o diagrama e escreve no rastro os
+// ciclos, o CPI e as bolhas por causa.
+func (m *Machine) escreverEstatisticasPipeline() {
+	p := m.pipeline
+	p.desenhar(p.ciclos() + 1)
+	cpi := 0.0
+	if p.instrucoes > 0 {
+		cpi = float64(p.ciclos()) / float64(p.instrucoes)
+	}
+	forwarding := "off"
+	if p.forwarding {
+		forwarding = "on"
+	}
+	fmt.Fprintf(m.trace, "#pipeline:stats    cycles=%d, instructions=%d, cpi=%.4f, forwarding=%s\n", p.ciclos(), p.instrucoes, cpi, forwarding)
+	fmt.Fprintf(m.trace, "#pipeline:stalls    raw=%d, load-use=%d, control=%d\n", p.bolhasRAW, p.bolhasLoadUso, p.bolhasControle)
+}
+
+// operandosPipeline retorna o registrador escrito pela instrução (-1 se
+// nenhum; x0 nunca cria dependência), os registradores lidos e se ela é
+// um load. Os registradores de ponto flutuante são numerados de 32 a 63.
+func operandosPipeline(instrucao uint32) (destino int, fontes []int, load bool) {
+	rd := int((instrucao >> 7) & 0x1F)
+	rs1 := int((instrucao >> 15) & 0x1F)
+	rs2 := int((instrucao >> 20) & 0x1F)
+	rs3 := int(instrucao >> 27)
+	const f = 32
+
+	switch instrucao & 0x7F {
+	case 0b0110111, 0b0010111, 0b1101111: // lui, auipc, jal
+		return rd, nil, false
+	case 0b1100111, 0b0010011: // jalr, tipo I
+		return rd, []int{rs1}, false
+	case 0b0000011: // loads
+		return rd, []int{rs1}, true
+	case 0b0100011, 0b1100011: // stores, desvios
+		return -1, []int{rs1, rs2}, false
+	case 0b0110011: // tipo R
+		return rd, []int{rs1, rs2}, false
+	case 0b0101111: // RV32A
+		return rd, []int{rs1, rs2}, true
+	case 0b0000111: // flw
+		return f + rd, []int{rs1}, true
+	case 0b0100111: // fsw
+		return -1, []int{rs1, f + rs2}, false
+	case 0b1000011, 0b1000111, 0b1001011, 0b1001111: // fmadd.s etc.
+		return f + rd, []int{f + rs1, f + rs2, f + rs3}, false
+	case 0b1010011: // OP-FP
+		switch instrucao >> 25 {
+		case 0b1010000: // feq.s, flt.s, fle.s
+			return rd, []int{f + rs1, f + rs2}, false
+		case 0b1100000, 0b1110000: // fcvt.w[u].s, fmv.x.w, fclass.s
+			return rd, []int{f + rs1}, false
+		case 0b1101000, 0b1111000: // fcvt.s.w[u], fmv.w.x
+			return f + rd, []int{rs1}, false
+		case 0b0101100: // fsqrt.s
+			return f + rd, []int{f + rs1}, false
+		}
+		return f + rd, []int{f + rs1, f + rs2}, false
+	case 0b1110011: // SYSTEM
+		switch {
+		case (instrucao>>12)&0x7 == 0: // ecall, ebreak, mret, sret, wfi
+			return -1, nil, false
+		case (instrucao>>12)&0x4 != 0: // formas imediatas de CSR
+			return rd, nil, false
+		}
+		return rd, []int{rs1}, false
+	}
+	return -1, nil, false
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"testing"
+)
+
+func (p *programa) add(rd, rs1, rs2 uint32) {
+	*p = append(*p, rs2<<20|rs1<<15|rd<<7|0x33)
+}
+
+// beqZero desvia 8 bytes à frente, sempre tomado (beq x0, x0).
+func (p *programa) beqZero() {
+	*p = append(*p, 8<<7|0x63)
+}
+
+// programaHazards tem um load-use, uma cadeia RAW (além da do li) e um
+// desvio tomado.
+func programaHazards() programa {
+	var p programa
+	p.li(regBase, enderecoDados)
+	p.load(0b010, regValor, regBase, 0)
+	p.add(regLido, regValor, regValor)
+	p.add(regResultado, regLido, regLido)
+	p.beqZero()
+	p.addi(0, 0, 0) // Descartada
+	p.ebreak()
+	return p
+}
+
+func TestPipelineBolhas(t *testing.T) {
+	casos := []struct {
+		nome                           string
+		forwarding                     bool
+		ciclos, raw, loadUso, controle uint64
+	}{
+		{"com adiantamento", true, 14, 0, 1, 2},
+		{"sem adiantamento", false, 21, 6, 2, 2},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			cfg := ProfileV2()
+			cfg.Pipeline, cfg.Forwarding = true, c.forwarding
+			m, _ := executarPrograma(t, cfg, programaHazards())
+			p := m.pipeline
+			if p.ciclos() != c.ciclos || p.bolhasRAW != c.raw || p.bolhasLoadUso != c.loadUso || p.bolhasControle != c.controle {
+				t.Errorf("ciclos=%d raw=%d load-use=%d control=%d, esperado %d %d %d %d",
+					p.ciclos(), p.bolhasRAW, p.bolhasLoadUso, p.bolhasControle, c.ciclos, c.raw, c.loadUso, c.controle)
+			}
+		})
+	}
+}
+
+// TestPipelineSequencias escalona sequências curtas diretamente no
+// modelo de pipeline e confere os ciclos e as bolhas de cada hazard.
+func TestPipelineSequencias(t *testing.T) {
+	var loadUso, raw, desvio programa
+	loadUso.load(0b010, 5, 1, 0) // lw x5, 0(x1)
+	loadUso.add(6, 5, 5)
+	raw.add(5, 1, 2)
+	raw.add(6, 5, 5)
+	desvio.beqZero()
+	desvio.add(6, 1, 2)
+
+	casos := []struct {
+		nome                           string
+		forwarding                     bool
+		programa                       programa
+		tomado                         int // Índice da instrução que muda o fluxo (-1 = nenhuma)
+		ciclos, raw, loadUso, controle uint64
+	}{
+		{"load-use com adiantamento", true, loadUso, -1, 7, 0, 1, 0},
+		{"raw com adiantamento", true, raw, -1, 6, 0, 0, 0},
+		{"raw sem adiantamento", false, raw, -1, 8, 2, 0, 0},
+		{"desvio tomado sem preditor", true, desvio, 0, 8, 0, 0, 2},
+		{"desvio não tomado", true, desvio, -1, 6, 0, 0, 0},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			p := novoPipeline(c.forwarding)
+			for i, instrucao := range c.programa {
+				p.emitir(RAMBase+uint32(4*i), instrucao, i == c.tomado)
+			}
+			if p.ciclos() != c.ciclos || p.bolhasRAW != c.raw || p.bolhasLoadUso != c.loadUso || p.bolhasControle != c.controle {
+				t.Errorf("ciclos=%d raw=%d load-use=%d control=%d, esperado %d %d %d %d",
+					p.ciclos(), p.bolhasRAW, p.bolhasLoadUso, p.bolhasControle, c.ciclos, c.raw, c.loadUso, c.controle)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package core
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// politicaSubstituicao escolhe a via a ser substituída em um conjunto
+// cheio. acessar é chamada a cada acerto e inserir quando um bloco é
+// carregado; vitima só é consultada quando todas as vias são válidas.
+type politicaSubstituicao interface {
+	acessar(c *Cache, index uint32, via int)
+	inserir(c *Cache, index uint32, via int)
+	vitima(c *Cache, index uint32) int
+}
+
+// Políticas disponíveis, pelo nome usado na linha de comando
+var politicas = map[string]func(seed int64) politicaSubstituicao{
+	"lru":    func(int64) politicaSubstituicao { return lru{} },
+	"fifo":   func(int64) politicaSubstituicao { return &fifo{} },
+	"random": func(seed int64) politicaSubstituicao { return aleatoria{rand.New(rand.NewSource(seed))} },
+	"plru":   func(int64) politicaSubstituicao { return plru{} },
+	"lfu":    func(int64) politicaSubstituicao { return lfu{} },
+}
+
+// Policies retorna os nomes das políticas de substituição, em ordem.
+func Policies() []string {
+	nomes := make([]string, 0, len(politicas))
+	for nome := range politicas {
+		nomes = append(nomes, nome)
+	}
+	sort.Strings(nomes)
+	return nomes
+}
+
+// novaPolitica cria a política pelo nome; o nome vazio é LRU.
+func novaPolitica(nome string, seed int64) (politicaSubstituicao, error) {
+	if nome == "" {
+		nome = "lru"
+	}
+	criar, ok := politicas[nome]
+	if !ok {
+		return nil, fmt.Errorf("política de substituição desconhecida: %s (disponíveis: %v)", nome, Policies())
+	}
+	return criar(seed), nil
+}
+
+// lru é a política original da v3: as idades do conjunto já são mantidas
+// pela cache e a vítima é a via de menor idade.
+type lru struct{}
+
+func (lru) acessar(*Cache, uint32, int) {}
+func (lru) inserir(*Cache, uint32, int) {}
+
+func (lru) vitima(c *Cache, index uint32) int {
+	victim := 0
+	for i := 1; i < c.assoc; i++ {
+		if c.sets[index].age[i] < c.sets[index].age[victim] {
+			victim = i
+		}
+	}
+	return victim
+}
+
+// fifo substitui o bloco carregado há mais tempo, ignorando os acertos.
+// estado guarda a ordem de chegada de cada via.
+type fifo struct {
+	chegada uint64
+}
+
+func (*fifo) acessar(*Cache, uint32, int) {}
+
+func (f *fifo) inserir(c *Cache, index uint32, via int) {
+	f.chegada++
+	c.sets[index].estado[via] = f.chegada
+}
+
+func (*fifo) vitima(c *Cache, index uint32) int {
+	return menorEstado(c, index)
+}
+
+// aleatoria sorteia a vítima com um gerador de semente fixa, para que a
+// execução seja reproduzível.
+type aleatoria struct {
+	rng *rand.Rand
+}
+
+func (aleatoria) acessar(*Cache, uint32, int) {}
+func (aleatoria) inserir(*Cache, uint32, int) {}
+
+func (a aleatoria) vitima(c *Cache, index uint32) int {
+	return a.rng.Intn(c.assoc)
+}
+
+// plru é o pseudo-LRU em árvore: cada nó interno aponta para a metade
+// menos recentemente usada. estado[0] guarda os assoc-1 bits da árvore,
+// com os filhos do nó n em 2n+1 e 2n+2.
+type plru struct{}
+
+func (p plru) acessar(c *Cache, index uint32, via int) {
+	arvore := c.sets[index].estado[0]
+	no, inicio, tam := 0, 0, c.assoc
+	for tam > 1 {
+		tam /= 2
+		if via < inicio+tam {
+			arvore |= 1 << no // Usada a esquerda: aponta para a direita
+			no = 2*no + 1
+		} else {
+			arvore &^= 1 << no
+			no, inicio = 2*no+2, inicio+tam
+		}
+	}
+	c.sets[index].estado[0] = arvore
+}
+
+func (p plru) inserir(c *Cache, index uint32, via int) {
+	p.acessar(c, index, via)
+}
+
+func (plru) vitima(c *Cache, index uint32) int {
+	arvore := c.sets[index].estado[0]
+	no, inicio, tam := 0, 0, c.assoc
+	for tam > 1 {
+		tam /= 2
+		if arvore&(1<<no) != 0 {
+			no, inicio = 2*no+2, inicio+tam
+		} else {
+			no = 2*no + 1
+		}
+	}
+	return inicio
+}
+
+// lfu substitui a via com menos acessos desde que foi carregada; empates
+// ficam com a via de menor índice. estado guarda a contagem de cada via.
+type lfu struct{}
+
+func (lfu) acessar(c *Cache, index uint32, via int) {
+	c.sets[index].estado[via]++
+}
+
+func (lfu) inserir(c *Cache, index uint32, via int) {
+	c.sets[index].estado[via] = 1
+}
+
+func (lfu) vitima(c *Cache, index uint32) int {
+	return menorEstado(c, index)
+}
+
+// menorEstado retorna a primeira via com o menor valor de estado.
+func menorEstado(c *Cache, index uint32) int {
+	victim := 0
+	for i := 1; i < c.assoc; i++ {
+		if c.sets[index].estado[i] < c.sets[index].estado[victim] {
+			victim = i
+		}
+	}
+	return victim
+}
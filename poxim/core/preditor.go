@@ -0,0 +1,292 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+)
+
+// preditorDirecao prevê o sentido dos desvios condicionais. prever é
+// consultada antes da resolução do desvio e atualizar recebe o resultado.
+type preditorDirecao interface {
+	prever(pc, alvo uint32) bool
+	atualizar(pc, alvo uint32, tomado bool)
+}
+
+// Preditores disponíveis, pelo nome usado na linha de comando; bits é o
+// número de bits de índice das tabelas
+var preditores = map[string]func(bits uint) preditorDirecao{
+	"static": func(uint) preditorDirecao { return estatico{} },
+	"btfn":   func(uint) preditorDirecao { return btfn{} },
+	"1bit":   func(bits uint) preditorDirecao { return &umBit{tabela: make([]bool, 1<<bits)} },
+	"2bit":   func(bits uint) preditorDirecao { return &doisBits{contadores: novosContadores(bits)} },
+	"gshare": func(bits uint) preditorDirecao { return &gshare{doisBits{contadores: novosContadores(bits)}, 0} },
+}
+
+// Predictors retorna os nomes dos preditores de desvio, em ordem.
+func Predictors() []string {
+	nomes := make([]string, 0, len(preditores))
+	for nome := range preditores {
+		nomes = append(nomes, nome)
+	}
+	sort.Strings(nomes)
+	return nomes
+}
+
+// BitsPreditorPadrao é o número de bits de índice usado quando a
+// configuração não define outro.
+const BitsPreditorPadrao = 10
+
+// ValidatePredictor verifica o nome do preditor e os bits de índice das
+// tabelas do preditor e da BTB.
+func ValidatePredictor(nome string, bits, bitsBTB int) error {
+	if _, ok := preditores[nome]; !ok {
+		return fmt.Errorf("preditor de desvios desconhecido: %s (disponíveis: %v)", nome, Predictors())
+	}
+	if bits < 0 || bits > 20 || bitsBTB < 0 || bitsBTB > 20 {
+		return fmt.Errorf("tabelas do preditor devem ter de 0 a 20 bits de índice")
+	}
+	return nil
+}
+
+// indiceTabela indexa uma tabela de 2^bits entradas pelo pc, ignorando o
+// bit 0, sempre zero.
+func indiceTabela(pc uint32, tam int) int {
+	return int(pc>>1) & (tam - 1)
+}
+
+// estatico prevê todo desvio como não tomado.
+type estatico struct{}
+
+func (estatico) prever(uint32, uint32) bool     { return false }
+func (estatico) atualizar(uint32, uint32, bool) {}
+
+// btfn prevê tomados os desvios para trás (laços) e não tomados os para
+// frente.
+type btfn struct{}
+
+func (btfn) prever(pc, alvo uint32) bool    { return alvo < pc }
+func (btfn) atualizar(uint32, uint32, bool) {}
+
+// umBit repete o último resultado de cada desvio.
+type umBit struct {
+	tabela []bool
+}
+
+func (p *umBit) prever(pc, _ uint32) bool {
+	return p.tabela[indiceTabela(pc, len(p.tabela))]
+}
+
+func (p *umBit) atualizar(pc, _ uint32, tomado bool) {
+	p.tabela[indiceTabela(pc, len(p.tabela))] = tomado
+}
+
+// doisBits usa contadores saturados de 2 bits: 0 e 1 preveem não tomado,
+// 2 e 3 tomado. Os contadores começam fracamente não tomados.
+type doisBits struct {
+	contadores []uint8
+}
+
+func novosContadores(bits uint) []uint8 {
+	contadores := make([]uint8, 1<<bits)
+	for i := range contadores {
+		contadores[i] = 1
+	}
+	return contadores
+}
+
+func (p *doisBits) prever(pc, _ uint32) bool {
+	return p.contadores[indiceTabela(pc, len(p.contadores))] >= 2
+}
+
+func (p *doisBits) atualizar(pc, _ uint32, tomado bool) {
+	p.contar(indiceTabela(pc, len(p.contadores)), tomado)
+}
+
+func (p *doisBits) contar(i int, tomado bool) {
+	if tomado && p.contadores[i] < 3 {
+		p.contadores[i]++
+	} else if !tomado && p.contadores[i] > 0 {
+		p.contadores[i]--
+	}
+}
+
+// gshare indexa os contadores de 2 bits pelo pc combinado (xor) com o
+// histórico global dos últimos desvios.
+type gshare struct {
+	doisBits
+	historico uint32
+}
+
+func (p *gshare) indice(pc uint32) int {
+	return int((pc>>1)^p.historico) & (len(p.contadores) - 1)
+}
+
+func (p *gshare) prever(pc, _ uint32) bool {
+	return p.contadores[p.indice(pc)] >= 2
+}
+
+func (p *gshare) atualizar(pc, _ uint32, tomado bool) {
+	p.contar(p.indice(pc), tomado)
+	p.historico <<= 1
+	if tomado {
+		p.historico |= 1
+	}
+}
+
+// btb é a tabela de alvos de desvio, de mapeamento direto, consultada por
+// jal e jalr.
+type btb struct {
+	entradas []entradaBTB
+}
+
+type entradaBTB struct {
+	valida   bool
+	pc, alvo uint32
+}
+
+// prever retorna o alvo guardado para o salto em pc, se houver.
+func (b *btb) prever(pc uint32) (uint32, bool) {
+	e := b.entradas[indiceTabela(pc, len(b.entradas))]
+	return e.alvo, e.valida && e.pc == pc
+}
+
+func (b *btb) atualizar(pc, alvo uint32) {
+	b.entradas[indiceTabela(pc, len(b.entradas))] = entradaBTB{true, pc, alvo}
+}
+
+// contagemPredicao conta execuções e predições corretas.
+type contagemPredicao struct {
+	execucoes, acertos int
+}
+
+func (c *contagemPredicao) contar(correta bool) {
+	c.execucoes++
+	if correta {
+		c.acertos++
+	}
+}
+
+func (c contagemPredicao) precisao() float64 {
+	if c.execucoes == 0 {
+		return 0
+	}
+	return float64(c.acertos) / float64(c.execucoes)
+}
+
+// predicao reúne o preditor de direção, a BTB e as estatísticas.
+type predicao struct {
+	nome    string
+	direcao preditorDirecao
+	btb     *btb // nil sem BTB
+	porPC   map[uint32]*contagemPredicao
+	desvios contagemPredicao
+	saltos  contagemPredicao
+}
+
+// novaPredicao cria o preditor da configuração, que deve ser válida (ver
+// ValidatePredictor).
+func novaPredicao(cfg Config) *predicao {
+	if err := ValidatePredictor(cfg.Preditor, cfg.BitsPreditor, cfg.BitsBTB); err != nil {
+		panic(err)
+	}
+	bits := cfg.BitsPreditor
+	if bits == 0 {
+		bits = BitsPreditorPadrao
+	}
+	p := &predicao{
+		nome:    cfg.Preditor,
+		direcao: preditores[cfg.Preditor](uint(bits)),
+		porPC:   make(map[uint32]*contagemPredicao),
+	}
+	if cfg.BitsBTB > 0 {
+		p.btb = &btb{entradas: make([]entradaBTB, 1<<cfg.BitsBTB)}
+	}
+	return p
+}
+
+// preverDesvio consulta e treina o preditor para o desvio condicional em
+// pc, define o próximo pc previsto e retorna a anotação do rastro.
+func (m *Machine) preverDesvio(pc, alvo uint32, tomado bool) string {
+	p := m.predicao
+	if p == nil {
+		return ""
+	}
+	previsto := p.direcao.prever(pc, alvo)
+	p.direcao.atualizar(pc, alvo, tomado)
+	if previsto {
+		m.pcPrevisto = alvo
+	}
+
+	correta := previsto == tomado
+	if !correta {
+		m.contarEvento(HPM_EVENT_MISPREDICT)
+	}
+	p.desvios.contar(correta)
+	c, ok := p.porPC[pc]
+	if !ok {
+		c = &contagemPredicao{}
+		p.porPC[pc] = c
+	}
+	c.contar(correta)
+
+	if !m.cfg.AnotarPredicao {
+		return ""
+	}
+	sentido := 0
+	if previsto {
+		sentido = 1
+	}
+	return fmt.Sprintf(" [pred=%d %s]", sentido, resultadoPredicao(correta))
+}
+
+// preverSalto consulta e atualiza a BTB para o jal ou jalr em pc.
+func (m *Machine) preverSalto(pc, alvo uint32) string {
+	p := m.predicao
+	if p == nil || p.btb == nil {
+		return ""
+	}
+	previsto, ok := p.btb.prever(pc)
+	p.btb.atualizar(pc, alvo)
+	if ok {
+		m.pcPrevisto = previsto
+	}
+	correta := ok && previsto == alvo
+	if !correta {
+		m.contarEvento(HPM_EVENT_MISPREDICT)
+	}
+	p.saltos.contar(correta)
+
+	if !m.cfg.AnotarPredicao {
+		return ""
+	}
+	if !ok {
+		return fmt.Sprintf(" [btb=miss %s]", resultadoPredicao(correta))
+	}
+	return fmt.Sprintf(" [btb=0x%08x %s]", previsto, resultadoPredicao(correta))
+}
+
+func resultadoPredicao(correta bool) string {
+	if correta {
+		return "correct"
+	}
+	return "mispredict"
+}
+
+// escreverEstatisticasPredicao escreve a precisão de cada desvio, em
+// ordem de endereço, a precisão total e a da BTB.
+func (m *Machine) escreverEstatisticasPredicao() {
+	p := m.predicao
+	pcs := make([]uint32, 0, len(p.porPC))
+	for pc := range p.porPC {
+		pcs = append(pcs, pc)
+	}
+	sort.Slice(pcs, func(i, j int) bool { return pcs[i] < pcs[j] })
+	for _, pc := range pcs {
+		c := p.porPC[pc]
+		fmt.Fprintf(m.trace, "#bpred:branch 0x%08x    count=%d, correct=%d, accuracy=%.4f%s\n", pc, c.execucoes, c.acertos, c.precisao(), m.anotar(pc))
+	}
+	fmt.Fprintf(m.trace, "#bpred:stats    predictor=%s, branches=%d, correct=%d, accuracy=%.4f\n", p.nome, p.desvios.execucoes, p.desvios.acertos, p.desvios.precisao())
+	if p.btb != nil {
+		fmt.Fprintf(m.trace, "#bpred:btb    jumps=%d, correct=%d, accuracy=%.4f\n", p.saltos.execucoes, p.saltos.acertos, p.saltos.precisao())
+	}
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"debug/elf"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// simbolo é uma entrada da tabela de símbolos do programa carregado.
+type simbolo struct {
+	nome     string
+	endereco uint32
+	tam      uint32
+}
+
+// tabelaSimbolos guarda os símbolos ordenados por endereço.
+type tabelaSimbolos []simbolo
+
+// lerSimbolos extrai do ELF as funções e rótulos com nome, ignorando
+// símbolos de seção, de arquivo e rótulos locais do montador (.L*).
+func lerSimbolos(f *elf.File) tabelaSimbolos {
+	simbolos, err := f.Symbols()
+	if err != nil {
+		return nil
+	}
+	var tabela tabelaSimbolos
+	for _, s := range simbolos {
+		tipo := elf.ST_TYPE(s.Info)
+		if s.Name == "" || strings.HasPrefix(s.Name, ".L") || s.Section == elf.SHN_UNDEF ||
+			(tipo != elf.STT_FUNC && tipo != elf.STT_NOTYPE) {
+			continue
+		}
+		tabela = append(tabela, simbolo{nome: s.Name, endereco: uint32(s.Value), tam: uint32(s.Size)})
+	}
+	// Em endereços repetidos, globais (vindas primeiro no ELF) ficam à
+	// frente pela ordenação estável
+	sort.SliceStable(tabela, func(i, j int) bool { return tabela[i].endereco < tabela[j].endereco })
+	return tabela
+}
+
+// buscar retorna o símbolo que contém endereco: o de maior endereço não
+// superior a ele, respeitando o tamanho quando conhecido.
+func (t tabelaSimbolos) buscar(endereco uint32) (simbolo, bool) {
+	i := sort.Search(len(t), func(i int) bool { return t[i].endereco > endereco })
+	if i == 0 {
+		return simbolo{}, false
+	}
+	// Entre símbolos no mesmo endereço, prefere o primeiro
+	s := t[i-1]
+	for i > 1 && t[i-2].endereco == s.endereco {
+		i--
+		s = t[i-1]
+	}
+	if s.tam != 0 && endereco-s.endereco >= s.tam {
+		return simbolo{}, false
+	}
+	return s, true
+}
+
+// Symbol formata endereco como <função+deslocamento>, ou retorna false se
+// nenhum símbolo o contém.
+func (m *Machine) Symbol(endereco uint32) (string, bool) {
+	s, ok := m.simbolos.buscar(endereco)
+	if !ok {
+		return "", false
+	}
+	if endereco == s.endereco {
+		return fmt.Sprintf("<%s>", s.nome), true
+	}
+	return fmt.Sprintf("<%s+0x%x>", s.nome, endereco-s.endereco), true
+}
+
+// SymbolAddr retorna o endereço do símbolo nome.
+func (m *Machine) SymbolAddr(nome string) (uint32, bool) {
+	for _, s := range m.simbolos {
+		if s.nome == nome {
+			return s.endereco, true
+		}
+	}
+	return 0, false
+}
+
+// anotar retorna o sufixo " <função+deslocamento>" para o rastro quando
+// as anotações estão habilitadas e endereco tem símbolo.
+func (m *Machine) anotar(endereco uint32) string {
+	if !m.cfg.AnotarSimbolos {
+		return ""
+	}
+	if s, ok := m.Symbol(endereco); ok {
+		return " " + s
+	}
+	return ""
+}
+
+// rotuloPC formata o pc no início de uma linha do rastro, seguido do
+// símbolo que o contém quando as anotações estão habilitadas.
+func (m *Machine) rotuloPC(pc uint32) string {
+	return fmt.Sprintf("0x%08x%s", pc, m.anotar(pc))
+}
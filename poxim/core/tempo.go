@@ -0,0 +1,140 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Latencies é a tabela de latências, em ciclos, do modelo de tempo. Cada
+// instrução custa a latência da sua classe mais a penalidade de desvio,
+// se mudar o fluxo, e o tempo dos acessos à memória que fizer. O acerto
+// de um ciclo numa cache L1 fica sobreposto à execução; latências de
+// acerto maiores, as faltas e os acessos sem cache somam ciclos de espera.
+type Latencies struct {
+	ALU    int // Inteiras, loads, stores e demais instruções
+	Mul    int // mul, mulh, mulhsu, mulhu
+	Div    int // div, divu, rem, remu
+	FPU    int // Ponto flutuante, exceto divisão e raiz
+	FDiv   int // fdiv.s e fsqrt.s
+	Branch int // Penalidade de desvio tomado, salto ou trap
+	L1     int // Acerto na icache ou na dcache
+	L2     int // Acerto na L2
+	Mem    int // Acesso à memória principal ou a um dispositivo
+}
+
+// DefaultLatencies retorna a tabela de latências padrão.
+func DefaultLatencies() Latencies {
+	return Latencies{ALU: 1, Mul: 3, Div: 20, FPU: 4, FDiv: 16, Branch: 2, L1: 1, L2: 10, Mem: 100}
+}
+
+// campos associa os nomes aceitos por ParseLatencies aos campos da tabela.
+func (l *Latencies) campos() map[string]*int {
+	return map[string]*int{
+		"alu": &l.ALU, "mul": &l.Mul, "div": &l.Div, "fpu": &l.FPU, "fdiv": &l.FDiv,
+		"branch": &l.Branch, "l1": &l.L1, "l2": &l.L2, "mem": &l.Mem,
+	}
+}
+
+// ParseLatencies altera a tabela base com uma lista "classe=ciclos,..."
+// (por exemplo "mul=3,div=20,mem=50").
+func ParseLatencies(texto string, base Latencies) (Latencies, error) {
+	campos := base.campos()
+	for _, item := range strings.Split(texto, ",") {
+		nome, valor, ok := strings.Cut(strings.TrimSpace(item), "=")
+		campo, existe := campos[nome]
+		if !ok || !existe {
+			nomes := make([]string, 0, len(campos))
+			for n := range campos {
+				nomes = append(nomes, n)
+			}
+			sort.Strings(nomes)
+			return Latencies{}, fmt.Errorf("latência inválida %q, esperado classe=ciclos com classe em %v", item, nomes)
+		}
+		ciclos, err := strconv.Atoi(valor)
+		if err != nil || ciclos < 0 {
+			return Latencies{}, fmt.Errorf("número de ciclos inválido em %q", item)
+		}
+		*campo = ciclos
+	}
+	if base.ALU < 1 {
+		return Latencies{}, fmt.Errorf("a latência alu deve ser de ao menos 1 ciclo")
+	}
+	return base, nil
+}
+
+// latenciaClasse retorna a latência de execução da instrução (já
+// expandida, se comprimida).
+func (l *Latencies) latenciaClasse(instrucao uint32) int {
+	switch instrucao & 0x7F {
+	case 0b0110011: // R-type
+		if instrucao>>25 == 0b0000001 {
+			if (instrucao>>12)&0x7 < 0b100 {
+				return l.Mul
+			}
+			return l.Div
+		}
+	case 0b1000011, 0b1000111, 0b1001011, 0b1001111: // fmadd.s etc.
+		return l.FPU
+	case 0b1010011: // OP-FP
+		switch instrucao >> 25 {
+		case 0b0001100, 0b0101100: // fdiv.s, fsqrt.s
+			return l.FDiv
+		}
+		return l.FPU
+	}
+	return l.ALU
+}
+
+// gastar soma ciclos de espera da instrução em execução.
+func (m *Machine) gastar(ciclos int) {
+	m.ciclos += uint64(ciclos)
+}
+
+// esperaAcerto retorna os ciclos de espera de um acerto na cache: o
+// primeiro ciclo de um acerto na L1 fica sobreposto à execução.
+func (m *Machine) esperaAcerto(cache *Cache) int {
+	if cache == m.L2 {
+		return m.cfg.Latencias.L2
+	}
+	return max(m.cfg.Latencias.L1-1, 0)
+}
+
+// medir atribui à cache um pedido iniciado no ciclo inicio, com os ciclos
+// gastos até agora para atendê-lo, para o tempo médio de acesso (AMAT).
+// Usada com defer no início do pedido.
+func (m *Machine) medir(cache *Cache, inicio uint64) {
+	cache.pedidos++
+	cache.ciclos += m.ciclos - inicio
+}
+
+// Cycles retorna o número de ciclos decorridos no modelo de tempo.
+func (m *Machine) Cycles() uint64 { return m.ciclos }
+
+// Instructions retorna o número de instruções executadas.
+func (m *Machine) Instructions() uint64 { return m.instrucoes }
+
+// escreverRelatorioTempo escreve no rastro o total de ciclos, de
+// instruções, o CPI e o tempo médio de acesso de cada cache.
+func (m *Machine) escreverRelatorioTempo() {
+	cpi := 0.0
+	if m.instrucoes > 0 {
+		cpi = float64(m.ciclos) / float64(m.instrucoes)
+	}
+	fmt.Fprintf(m.trace, "#timing:stats    cycles=%d, instructions=%d, cpi=%.4f\n", m.ciclos, m.instrucoes, cpi)
+	for _, c := range []struct {
+		nome  string
+		cache *Cache
+	}{{"icache", m.ICache}, {"dcache", m.DCache}, {"l2", m.L2}} {
+		if c.cache == nil || c.cache.pedidos == 0 {
+			continue
+		}
+		// O ciclo sobreposto dos acertos na L1 também entra no AMAT
+		amat := float64(c.cache.ciclos) / float64(c.cache.pedidos)
+		if c.cache != m.L2 {
+			amat += float64(min(m.cfg.Latencias.L1, 1))
+		}
+		fmt.Fprintf(m.trace, "#timing:%s    amat=%.4f\n", c.nome, amat)
+	}
+}
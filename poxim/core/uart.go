@@ -0,0 +1,206 @@
+package core
+
+import (
+	"io"
+)
+
+// Mapa de registradores da UART 16550, com um registrador por byte, no
+// endereço usado pelo QEMU virt
+const (
+	UARTBase uint32 = 0x10000000
+	UARTSize uint32 = 0x100
+
+	uartRBR = 0 // Receive buffer (leitura) / transmit holding (escrita)
+	uartIER = 1 // Interrupt enable
+	uartIIR = 2 // Interrupt identification (leitura) / FIFO control (escrita)
+	uartLCR = 3 // Line control
+	uartMCR = 4 // Modem control
+	uartLSR = 5 // Line status
+	uartMSR = 6 // Modem status
+	uartSCR = 7 // Scratch
+
+	uartIER_RDA  = 1 << 0 // Interrupção de dado recebido
+	uartIER_THRE = 1 << 1 // Interrupção de transmissor vazio
+	uartLCR_DLAB = 1 << 7 // Acesso aos divisores de baud rate
+	uartLSR_DR   = 1 << 0 // Dado pronto para leitura
+	uartLSR_THRE = 1 << 5 // Transmissor pronto
+	uartLSR_TEMT = 1 << 6 // Transmissor vazio
+
+	uartFIFO = 16 // Profundidade da FIFO de recepção
+)
+
+// UART emula uma 16550 simplificada: a transmissão é imediata e a
+// recepção é alimentada por um io.Reader lido em segundo plano. Quando
+// irq não é nil, as interrupções habilitadas em IER são sinalizadas em
+// MEIP.
+type UART struct {
+	tx  io.Writer
+	rx  chan byte
+	irq func(bit uint32, pendente bool)
+
+	fifo        []byte
+	ier, lcr    uint8
+	mcr, scr    uint8
+	dll, dlm    uint8
+	fifoHab     bool // FIFOs habilitadas em FCR
+	thrPendente bool // Interrupção de transmissor vazio não reconhecida
+	linhaArmada bool // Estado atual de MEIP dirigido pela UART
+}
+
+// NewUART cria uma UART que transmite para tx e recebe de rx. rx pode ser
+// nil para uma UART sem recepção; irq pode ser nil para desligar a
+// interrupção.
+func NewUART(tx io.Writer, rx io.Reader, irq func(bit uint32, pendente bool)) *UART {
+	if tx == nil {
+		tx = io.Discard
+	}
+	u := &UART{tx: tx, irq: irq}
+	if rx != nil {
+		u.rx = make(chan byte, uartFIFO)
+		go func() {
+			buf := make([]byte, 1)
+			for {
+				if _, err := rx.Read(buf); err != nil {
+					close(u.rx)
+					return
+				}
+				u.rx <- buf[0]
+			}
+		}()
+	}
+	return u
+}
+
+// Tick move os bytes já recebidos para a FIFO e atualiza a interrupção.
+func (u *UART) Tick() {
+	u.receber()
+	u.atualizar()
+}
+
+// receber drena o canal de recepção sem bloquear, até encher a FIFO.
+func (u *UART) receber() {
+	for u.rx != nil && len(u.fifo) < uartFIFO {
+		select {
+		case c, ok := <-u.rx:
+			if !ok {
+				u.rx = nil // Fim da entrada
+				return
+			}
+			u.fifo = append(u.fifo, c)
+		default:
+			return
+		}
+	}
+}
+
+// identificacao retorna o código de IIR da interrupção mais prioritária.
+func (u *UART) identificacao() uint8 {
+	switch {
+	case u.ier&uartIER_RDA != 0 && len(u.fifo) > 0:
+		return 0x04
+	case u.ier&uartIER_THRE != 0 && u.thrPendente:
+		return 0x02
+	}
+	return 0x01 // Nenhuma interrupção pendente
+}
+
+// atualizar dirige MEIP conforme a interrupção pendente, apenas nas
+// transições.
+func (u *UART) atualizar() {
+	if u.irq == nil {
+		return
+	}
+	pendente := u.identificacao() != 0x01
+	if pendente != u.linhaArmada {
+		u.irq(MIP_MEIP_BIT, pendente)
+		u.linhaArmada = pendente
+	}
+}
+
+// Read lê um registrador; acessos maiores que um byte retornam o
+// registrador no byte menos significativo.
+func (u *UART) Read(offset uint32, tam int) (uint32, bool) {
+	if offset > uartSCR {
+		return 0, false
+	}
+	var valor uint8
+	dlab := u.lcr&uartLCR_DLAB != 0
+	switch offset {
+	case uartRBR:
+		if dlab {
+			valor = u.dll
+		} else if len(u.fifo) > 0 {
+			valor = u.fifo[0]
+			u.fifo = u.fifo[1:]
+		}
+	case uartIER:
+		if dlab {
+			valor = u.dlm
+		} else {
+			valor = u.ier
+		}
+	case uartIIR:
+		valor = u.identificacao()
+		if valor == 0x02 {
+			u.thrPendente = false // Ler IIR reconhece o transmissor vazio
+		}
+		if u.fifoHab {
+			valor |= 0xC0
+		}
+	case uartLCR:
+		valor = u.lcr
+	case uartMCR:
+		valor = u.mcr
+	case uartLSR:
+		valor = uartLSR_THRE | uartLSR_TEMT
+		if len(u.fifo) > 0 {
+			valor |= uartLSR_DR
+		}
+	case uartMSR:
+		valor = 0
+	case uartSCR:
+		valor = u.scr
+	}
+	u.atualizar()
+	return uint32(valor), true
+}
+
+// Write escreve um registrador; apenas o byte menos significativo é usado.
+func (u *UART) Write(offset uint32, tam int, valor uint32) bool {
+	if offset > uartSCR {
+		return false
+	}
+	b := uint8(valor)
+	dlab := u.lcr&uartLCR_DLAB != 0
+	switch offset {
+	case uartRBR:
+		if dlab {
+			u.dll = b
+		} else {
+			u.tx.Write([]byte{b})
+			u.thrPendente = true
+		}
+	case uartIER:
+		if dlab {
+			u.dlm = b
+		} else {
+			if b&uartIER_THRE != 0 && u.ier&uartIER_THRE == 0 {
+				u.thrPendente = true
+			}
+			u.ier = b & 0x0F
+		}
+	case uartIIR: // FCR
+		u.fifoHab = b&0x1 != 0
+		if b&0x2 != 0 {
+			u.fifo = u.fifo[:0]
+		}
+	case uartLCR:
+		u.lcr = b
+	case uartMCR:
+		u.mcr = b & 0x1F
+	case uartSCR:
+		u.scr = b
+	}
+	u.atualizar()
+	return true
+}